@@ -0,0 +1,65 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStateActions(t *testing.T) {
+	var trace []string
+	handle := func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		trace = append(trace, "func")
+		return HandleRetCode(*n), nil
+	}
+	action := func(name string, err error) StateFunc[*door, *int] {
+		return func(d *door, state State, ev Event, n *int) error {
+			trace = append(trace, name+" "+state.Name)
+			return err
+		}
+	}
+	errEnter := errors.New("enter failed")
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "A",
+		LogMax:    10,
+		States: []StateDesc[*door, *int]{
+			{State: "A", OnEnter: action("enter", nil), OnExit: action("exit", nil),
+				Events: []EventDesc[*door, *int]{{Event: "T", Func: handle, CandList: []string{"B", "A"}}}},
+			{State: "B", OnEnter: action("enter", errEnter), OnExit: action("exit", nil),
+				Events: []EventDesc[*door, *int]{{Event: "T", Func: handle, CandList: []string{"A"}}}},
+		},
+	})
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	// the same State calls no action
+	if _, _, err := d.entry.TransitWithData("T", code(1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace) != 1 {
+		t.Fatalf("trace %v, expected [func]", trace)
+	}
+
+	trace = nil
+	state, _, err := d.entry.TransitWithData("T", code(0))
+	var ae *StateActionError
+	if !errors.As(err, &ae) || !errors.Is(err, errEnter) || ae.Action != "OnEnter" || ae.State != "B" {
+		t.Fatalf("error %v, expected *StateActionError of OnEnter B", err)
+	}
+	// the State is changed, even if OnEnter failed
+	if state.Name != "B" {
+		t.Fatalf("state %s, expected B", state.Name)
+	}
+	expected := []string{"func", "exit A", "enter B"}
+	if len(trace) != len(expected) {
+		t.Fatalf("trace %v, expected %v", trace, expected)
+	}
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Fatalf("trace %v, expected %v", trace, expected)
+		}
+	}
+	logs := d.entry.LogRecords(1)
+	if logs[0].EnterErr != err.Error() {
+		t.Fatalf("log %+v, expected EnterErr", logs[0])
+	}
+}
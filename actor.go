@@ -0,0 +1,158 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// FSM Transition result of the posted event
+// State, EOT and Err are the return values of TransitWithData()
+type Result struct {
+	Event string // posted Event
+	State State  // next state
+	EOT   bool   // represents end of transition
+	Err   error  // handler returned error
+}
+
+// Entry Running Error, other Run() is processing the Entry
+type EntryRunning struct {
+	ID  string // Entry ID, empty if no Store
+	Err error
+}
+
+func (e *EntryRunning) Error() string {
+	return e.Err.Error() + ": ID=" + e.ID
+}
+
+func (e *EntryRunning) Unwrap() error { return e.Err }
+
+// posted event, waiting for Run()
+type posted[USERDATA any] struct {
+	ctx    context.Context // context of the event, context.Background() if nil
+	event  string
+	data   USERDATA
+	result chan Result // nil if fire-and-forget
+	gen    uint64      // timer generation, if posted by the state timeout
+}
+
+// FSM event queue of the Entry, unbounded
+type queue[USERDATA any] struct {
+	mu      sync.Mutex
+	events  []posted[USERDATA]
+	wake    chan struct{} // signaled when events are added
+	running bool
+	onRes   func(Result)
+}
+
+func (q *queue[USERDATA]) push(p posted[USERDATA]) {
+	q.mu.Lock()
+	q.events = append(q.events, p)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue[USERDATA]) pop() (posted[USERDATA], bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.events) == 0 {
+		return posted[USERDATA]{}, false
+	}
+	p := q.events[0]
+	q.events[0] = posted[USERDATA]{}
+	q.events = q.events[1:]
+	return p, true
+}
+
+// Post queues the event, processed by Run() later
+// it never blocks, so HandleFunc can Post() follow-up events of the same Entry
+func (e *Entry[OWNER, USERDATA]) Post(ev string, userData USERDATA) {
+	e.queue.push(posted[USERDATA]{event: ev, data: userData})
+}
+
+// PostResult queues the event like Post(),
+// the returned channel receives the Result once the event is processed
+func (e *Entry[OWNER, USERDATA]) PostResult(ev string, userData USERDATA) <-chan Result {
+	ch := make(chan Result, 1)
+	e.queue.push(posted[USERDATA]{event: ev, data: userData, result: ch})
+	return ch
+}
+
+// OnResult sets the callback called by Run() with the Result of every posted event,
+// and with the Result of the state timeout injected while Run() is not processing the Entry
+// f runs on the goroutine of Run() or of the Clock, nil removes the callback
+func (e *Entry[OWNER, USERDATA]) OnResult(f func(Result)) {
+	e.queue.mu.Lock()
+	defer e.queue.mu.Unlock()
+	e.queue.onRes = f
+}
+
+// Pending returns the number of posted events not processed yet
+func (e *Entry[OWNER, USERDATA]) Pending() int {
+	e.queue.mu.Lock()
+	defer e.queue.mu.Unlock()
+	return len(e.queue.events)
+}
+
+// Run processes the posted events one at a time, until ctx is done
+// events still queued when ctx is done are kept for the next Run()
+// returns
+//
+//	error - ctx.Err(), or *EntryRunning if other Run() is processing the Entry
+func (e *Entry[OWNER, USERDATA]) Run(ctx context.Context) error {
+	q := &e.queue
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return &EntryRunning{ID: e.ID(), Err: fsmerror.ErrEntryRunning}
+	}
+	q.running = true
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.running = false
+		q.mu.Unlock()
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		p, ok := q.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-q.wake:
+			}
+			continue
+		}
+
+		pctx := p.ctx
+		if pctx == nil {
+			pctx = context.Background()
+		}
+		state, eot, err := e.transit(pctx, p.event, p.data, p.gen)
+		if err == errStaleTimeout {
+			continue
+		}
+		res := Result{Event: p.event, State: state, EOT: eot, Err: err}
+		if p.result != nil {
+			p.result <- res
+		}
+
+		q.mu.Lock()
+		onRes := q.onRes
+		q.mu.Unlock()
+		if onRes != nil {
+			onRes(res)
+		}
+	}
+}
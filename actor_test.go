@@ -0,0 +1,64 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunPostedEvents(t *testing.T) {
+	// the handle posts the follow-up events until the counter is 0
+	step := func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		if *n > 0 {
+			d.entry.Post("T", code(*n-1))
+		}
+		return ExitOK, nil
+	}
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "A",
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []EventDesc[*door, *int]{{Event: "T", Func: step, CandList: []string{"B"}}}},
+			{State: "B", Events: []EventDesc[*door, *int]{{Event: "T", Func: step, CandList: []string{"A"}}}},
+		},
+	})
+	d := &door{}
+	d.entry = tbl.NewSyncEntry(d)
+
+	results := make(chan Result, 16)
+	d.entry.OnResult(func(r Result) { results <- r })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- d.entry.Run(ctx) }()
+
+	r := <-d.entry.PostResult("T", code(4))
+	if r.State.Name != "B" || r.Err != nil {
+		t.Fatalf("result %+v, expected B", r)
+	}
+	// the posted event and its 4 follow-ups
+	for i := 0; i < 5; i++ {
+		select {
+		case <-results:
+		case <-time.After(time.Second):
+			t.Fatalf("%d results, expected 5", i)
+		}
+	}
+	expectState(t, d.entry, "B")
+
+	var running *EntryRunning
+	if err := d.entry.Run(ctx); !errors.As(err, &running) {
+		t.Fatalf("second Run() returned %v, expected *EntryRunning", err)
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Run() returned %v, expected context.Canceled", err)
+	}
+
+	// kept for the next Run()
+	d.entry.Post("T", code(0))
+	if n := d.entry.Pending(); n != 1 {
+		t.Fatalf("%d pending, expected 1", n)
+	}
+}
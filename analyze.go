@@ -0,0 +1,248 @@
+package fsm
+
+import (
+	"sort"
+	"strings"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// FSM Table static analysis report
+// all lists are sorted by name
+type Analysis struct {
+	Unreachable     []string // states not reachable from InitState
+	Traps           []string // reachable non-final leaf states with no path to any final state
+	UnusedFinals    []string // final states neither the InitState nor the next state of any handle
+	UnhandledEvents []string // events handled by unreachable states only
+}
+
+// OK reports the analysis has no finding
+func (a *Analysis) OK() bool {
+	return len(a.Unreachable) == 0 && len(a.Traps) == 0 &&
+		len(a.UnusedFinals) == 0 && len(a.UnhandledEvents) == 0
+}
+
+// Table Analysis Error, NewTable returns it if TableDesc.Strict is set
+type AnalysisError struct {
+	Analysis *Analysis
+	Err      error
+}
+
+func (e *AnalysisError) Error() string {
+	a := e.Analysis
+	msg := e.Err.Error() + ":"
+	sep := " "
+	for _, f := range []struct {
+		name  string
+		names []string
+	}{
+		{"Unreachable", a.Unreachable},
+		{"Traps", a.Traps},
+		{"UnusedFinals", a.UnusedFinals},
+		{"UnhandledEvents", a.UnhandledEvents},
+	} {
+		if len(f.names) > 0 {
+			msg += sep + f.name + "=[" + strings.Join(f.names, " ") + "]"
+			sep = ", "
+		}
+	}
+	return msg
+}
+
+func (e *AnalysisError) Unwrap() error { return e.Err }
+
+// successors returns the next states of the state, for any event
+// handles of the ancestors and the wildcards are included unless overridden, see candidates(),
+// composite next states are resolved to the leaf states entered,
+// history targets to every state of the group,
+// and the PanicState and the next states of TableDesc.ErrMap are the next states of any state having a handle
+func (tbl *Table[OWNER, USERDATA]) successors(state State) []State {
+	nexts := make([]State, 0)
+	for event := range tbl.Events {
+		for _, key := range tbl.candidates(state, event) {
+			handle, ok := tbl.Handles[key.state][key.event]
+			if !ok {
+				continue
+			}
+			accepts := false
+			for ; handle != nil; handle = handle.Alt {
+				for _, next := range handle.CandMap {
+					for _, target := range tbl.targets(next) {
+						nexts = append(nexts, tbl.leaves(target)...)
+					}
+				}
+				for _, r := range handle.ErrMap {
+					for _, target := range tbl.targets(r.Next) {
+						nexts = append(nexts, tbl.leaves(target)...)
+					}
+				}
+				accepts = accepts || !handle.guarded()
+			}
+			if accepts {
+				// the following handles are overridden
+				break
+			}
+		}
+	}
+	if len(nexts) > 0 && tbl.PanicPolicy == PanicRoute {
+		// any handle may panic
+		nexts = append(nexts, tbl.leaves(tbl.PanicState)...)
+	}
+	if len(nexts) > 0 {
+		// any handle may return the error
+		for _, r := range tbl.ErrMap {
+			nexts = append(nexts, tbl.leaves(State{r.Next})...)
+		}
+	}
+	return nexts
+}
+
+// nextStates returns the next states of every handle, overridden or not,
+// and the states entered through them or by the InitState, see successors()
+func (tbl *Table[OWNER, USERDATA]) nextStates() map[State]interface{} {
+	nexts := make(map[State]interface{})
+	add := func(next string) {
+		for _, target := range tbl.targets(next) {
+			nexts[target] = nil
+			for _, leaf := range tbl.leaves(target) {
+				nexts[leaf] = nil
+			}
+		}
+	}
+	for _, events := range tbl.Handles {
+		for _, handle := range events {
+			for ; handle != nil; handle = handle.Alt {
+				for _, next := range handle.CandMap {
+					add(next)
+				}
+				for _, r := range handle.ErrMap {
+					add(r.Next)
+				}
+			}
+		}
+	}
+	for _, r := range tbl.ErrMap {
+		add(r.Next)
+	}
+	if tbl.PanicPolicy == PanicRoute {
+		add(tbl.PanicState.Name)
+	}
+	for _, leaf := range tbl.leaves(tbl.InitState) {
+		nexts[leaf] = nil
+	}
+	return nexts
+}
+
+func sortedNames(set map[string]interface{}) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Analyze checks the reachability of the table
+// traps are reported only if the table has any final state
+func (tbl *Table[OWNER, USERDATA]) Analyze() *Analysis {
+	// forward search from the InitState
+	reached := make(map[State]interface{})
+	queue := tbl.leaves(tbl.InitState)
+	for _, state := range queue {
+		reached[state] = nil
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, next := range tbl.successors(state) {
+			if _, ok := reached[next]; !ok {
+				reached[next] = nil
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	// the composite state is reached if any of its descendants is reached
+	for state := range reached {
+		for parent, ok := tbl.Parents[state]; ok; parent, ok = tbl.Parents[parent] {
+			reached[parent] = nil
+		}
+	}
+
+	// backward search from the final states
+	preds := make(map[State][]State)
+	for state := range tbl.States {
+		for _, next := range tbl.successors(state) {
+			preds[next] = append(preds[next], state)
+		}
+	}
+	alive := make(map[State]interface{})
+	queue = queue[:0]
+	for name := range tbl.FSMap {
+		alive[State{name}] = nil
+		queue = append(queue, State{name})
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, prev := range preds[state] {
+			if _, ok := alive[prev]; !ok {
+				alive[prev] = nil
+				queue = append(queue, prev)
+			}
+		}
+	}
+
+	unreachable := make(map[string]interface{})
+	traps := make(map[string]interface{})
+	for state := range tbl.States {
+		if _, ok := reached[state]; !ok {
+			unreachable[state.Name] = nil
+			continue
+		}
+		if _, final := tbl.FSMap[state.Name]; final || len(tbl.FSMap) == 0 || tbl.composite(state) {
+			continue
+		}
+		if _, ok := alive[state]; !ok {
+			traps[state.Name] = nil
+		}
+	}
+
+	unused := make(map[string]interface{})
+	targeted := tbl.nextStates()
+	for name := range tbl.FSMap {
+		if _, ok := targeted[State{name}]; !ok && name != tbl.InitState.Name {
+			unused[name] = nil
+		}
+	}
+
+	unhandled := make(map[string]interface{})
+	for event := range tbl.Events {
+		handled := false
+		for state := range reached {
+			if tbl.hasHandle(state, event) {
+				handled = true
+				break
+			}
+		}
+		if !handled {
+			unhandled[event.Name] = nil
+		}
+	}
+
+	return &Analysis{
+		Unreachable:     sortedNames(unreachable),
+		Traps:           sortedNames(traps),
+		UnusedFinals:    sortedNames(unused),
+		UnhandledEvents: sortedNames(unhandled),
+	}
+}
+
+// check runs Analyze and reports the findings as error
+func (tbl *Table[OWNER, USERDATA]) check() error {
+	a := tbl.Analyze()
+	if a.OK() {
+		return nil
+	}
+	return &AnalysisError{Analysis: a, Err: fsmerror.ErrAnalysis}
+}
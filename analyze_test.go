@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// flawedDesc returns the door with a finding of every kind,
+// the final State Opened is mistyped as "Opned"
+func flawedDesc() *TableDesc[*door, *int] {
+	type E = EventDesc[*door, *int]
+	desc := doorDesc()
+	desc.FinalStates = []string{"Locked", "Opned", "Broken"}
+	desc.States[0].Events = append(desc.States[0].Events, E{Event: "Jam", Func: retCode, CandList: []string{"Jammed"}})
+	desc.States = append(desc.States,
+		// never leaves
+		StateDesc[*door, *int]{State: "Jammed", Events: []E{{Event: "Jam", Func: retCode, CandList: []string{"Jammed"}}}},
+		// declared, but never the next state
+		StateDesc[*door, *int]{State: "Broken"},
+		// Kick is handled only here
+		StateDesc[*door, *int]{State: "Attic", Events: []E{{Event: "Kick", Func: retCode, CandList: []string{"Broken"}}}},
+	)
+	return desc
+}
+
+func TestAnalyzeOK(t *testing.T) {
+	if a := newTestTable(t, doorDesc()).Analyze(); !a.OK() {
+		t.Fatalf("unexpected findings %+v", a)
+	}
+}
+
+func TestAnalyzeFindings(t *testing.T) {
+	a := newTestTable(t, flawedDesc()).Analyze()
+	expected := &Analysis{
+		Unreachable:     []string{"Attic", "Broken"},
+		Traps:           []string{"Jammed"},
+		UnusedFinals:    []string{"Opned"},
+		UnhandledEvents: []string{"Kick"},
+	}
+	if a.OK() || !reflect.DeepEqual(a, expected) {
+		t.Fatalf("got %+v, expected %+v", a, expected)
+	}
+}
+
+func TestAnalyzeUnusedFinal(t *testing.T) {
+	// Broken is declared, but no handle moves to it
+	desc := doorDesc()
+	desc.FinalStates = []string{"Locked", "Broken"}
+	desc.States = append(desc.States, StateDesc[*door, *int]{State: "Broken"})
+	a := newTestTable(t, desc).Analyze()
+	if !reflect.DeepEqual(a.UnusedFinals, []string{"Broken"}) {
+		t.Fatalf("UnusedFinals %v, expected [Broken]", a.UnusedFinals)
+	}
+}
+
+func TestAnalyzeStrict(t *testing.T) {
+	desc := flawedDesc()
+	desc.Strict = true
+	_, err := NewTable(desc)
+	var analysis *AnalysisError
+	if !errors.As(err, &analysis) || !errors.Is(err, fsmerror.ErrAnalysis) {
+		t.Fatalf("expected *AnalysisError, got %v", err)
+	}
+	expected := analysis.Err.Error() +
+		": Unreachable=[Attic Broken], Traps=[Jammed], UnusedFinals=[Opned], UnhandledEvents=[Kick]"
+	if err.Error() != expected {
+		t.Fatalf("got %q, expected %q", err.Error(), expected)
+	}
+}
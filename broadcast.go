@@ -0,0 +1,105 @@
+package fsm
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// FSM Transition result of the broadcast event, per Entry
+type BroadcastResult[K comparable] struct {
+	Key K
+	Result
+}
+
+// FSM Broadcast report, the results of all Entries the event was sent to
+type BroadcastReport[K comparable] struct {
+	Event   string
+	Results []BroadcastResult[K] // in no particular order
+}
+
+// Failed returns the results with error
+func (r *BroadcastReport[K]) Failed() []BroadcastResult[K] {
+	failed := make([]BroadcastResult[K], 0)
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// OK reports every Entry handled the event without error
+func (r *BroadcastReport[K]) OK() bool {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Broadcast sends the event to every Entry, see BroadcastFunc()
+func (m *Manager[K, OWNER, USERDATA]) Broadcast(ctx context.Context, ev string, userData USERDATA, parallel int) *BroadcastReport[K] {
+	return m.BroadcastFunc(ctx, ev, userData, parallel, nil)
+}
+
+// BroadcastState sends the event to every Entry in the State, see BroadcastFunc()
+// the State is compared with CurrentState() of the Entry, when the Entries are selected
+func (m *Manager[K, OWNER, USERDATA]) BroadcastState(ctx context.Context, state State, ev string, userData USERDATA, parallel int) *BroadcastReport[K] {
+	return m.BroadcastFunc(ctx, ev, userData, parallel, func(key K, entry *Entry[OWNER, USERDATA]) bool {
+		return entry.CurrentState() == state
+	})
+}
+
+// BroadcastFunc sends the event to every Entry accepted by filter, nil filter accepts all,
+// see TransitContext()
+// at most parallel Entries transit at the same time, runtime.GOMAXPROCS(0) if 0
+// the Entries are selected before any transition, the Entries created meanwhile are not included
+// if ctx is done, the remaining Entries report *TransitCanceled
+// userData is shared by the Entries transiting at the same time
+func (m *Manager[K, OWNER, USERDATA]) BroadcastFunc(ctx context.Context, ev string, userData USERDATA, parallel int, filter func(key K, entry *Entry[OWNER, USERDATA]) bool) *BroadcastReport[K] {
+	keys := make([]K, 0)
+	entries := make([]*Entry[OWNER, USERDATA], 0)
+	m.Range(func(key K, entry *Entry[OWNER, USERDATA]) bool {
+		if filter == nil || filter(key, entry) {
+			keys = append(keys, key)
+			entries = append(entries, entry)
+		}
+		return true
+	})
+
+	report := &BroadcastReport[K]{
+		Event:   ev,
+		Results: make([]BroadcastResult[K], len(entries)),
+	}
+	if parallel <= 0 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+	if parallel > len(entries) {
+		parallel = len(entries)
+	}
+
+	var wg sync.WaitGroup
+	var next atomic.Int64
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(entries) {
+					return
+				}
+				state, eot, err := entries[i].TransitContext(ctx, ev, userData)
+				report.Results[i] = BroadcastResult[K]{
+					Key:    keys[i],
+					Result: Result{Event: ev, State: state, EOT: eot, Err: err},
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
@@ -0,0 +1,53 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	tbl := endTable(t, NewFakeClock(time.Unix(0, 0)))
+	// at most parallel handles run at the same time
+	var running, peak atomic.Int64
+	tbl.Handles[State{"A"}][Event{"T"}].Func = func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		c := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); c > p && !peak.CompareAndSwap(p, c); p = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		return HandleRetCode(*n), nil
+	}
+
+	m := NewManager[string](tbl, 8, nil)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		m.Create(key, &door{})
+	}
+	m.Dispatch("a", "T", code(0))
+
+	r := m.BroadcastState(context.Background(), State{"A"}, "T", code(0), 2)
+	if len(r.Results) != 4 || !r.OK() || peak.Load() > 2 {
+		t.Fatalf("%d results ok %v peak %d, expected 4 Entries in A, 2 at a time", len(r.Results), r.OK(), peak.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = m.Broadcast(ctx, "T", code(0), 0)
+	var tc *TransitCanceled
+	if failed := r.Failed(); len(failed) != 5 || !errors.As(failed[0].Err, &tc) {
+		t.Fatalf("failed %+v, expected every Entry canceled", failed)
+	}
+
+	// the ended Entries are evicted
+	r = m.BroadcastFunc(context.Background(), "T", code(1), 0, func(key string, entry *Entry[*door, *int]) bool {
+		return key != "a"
+	})
+	if len(r.Results) != 4 || m.Len() != 1 {
+		t.Fatalf("%d results %d entries, expected all but a ended", len(r.Results), m.Len())
+	}
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("a is evicted, expected filtered out")
+	}
+}
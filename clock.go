@@ -0,0 +1,119 @@
+package fsm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timer returned by Clock.AfterFunc()
+type Timer interface {
+	// Stop prevents the Timer from firing,
+	// returns false if the Timer already fired or stopped
+	Stop() bool
+}
+
+// Clock provides the time to the FSM, for log and state timeout
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine after duration d elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// SystemClock is the Clock of the time package, default Clock of Table
+var SystemClock Clock = systemClock{}
+
+// FakeClock is the Clock which moves only by Advance(), for test
+// timers fire synchronously on the goroutine calling Advance()
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64 // timer creation order, for timers of the same deadline
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	seq   uint64
+	f     func()
+}
+
+// NewFakeClock creates FakeClock starts at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t := &fakeTimer{clock: c, when: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Timers returns the number of timers not fired yet
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by d,
+// fires the expired timers in order of deadline, including timers created by them
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		sort.Slice(c.timers, func(i, j int) bool {
+			if !c.timers[i].when.Equal(c.timers[j].when) {
+				return c.timers[i].when.Before(c.timers[j].when)
+			}
+			return c.timers[i].seq < c.timers[j].seq
+		})
+		if len(c.timers) == 0 || c.timers[0].when.After(end) {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		c.mu.Unlock()
+
+		t.f()
+	}
+}
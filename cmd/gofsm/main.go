@@ -0,0 +1,228 @@
+// gofsm validates and renders the table specification file, without the handlers
+//
+//	gofsm validate door.yaml
+//	gofsm render -format mermaid -o door.mmd door.yaml
+//	gofsm dump door.yaml
+//
+// validate checks the table as NewTable() does, then its reachability as Analyze() does
+// render and dump check the table as NewTable() does, and report the reachability as warnings
+//
+// exit codes
+//
+//	0 - OK
+//	1 - other errors, e.g. the file is not found or not a valid specification
+//	2 - invalid command line
+//	3 - conflict handle, *StateEventConflictError
+//	4 - duplicated return code, *HandleRetCodeDupError
+//	5 - handle not exists, *UndefinedHandle
+//	6 - reachability, *AnalysisError
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	fsm "github.com/HaesungSeo/goFSM/v2"
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// exit codes
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitConflict  = 3
+	exitDupCode   = 4
+	exitNoHandle  = 5
+	exitReachable = 6
+)
+
+// the handlers are not called, only the structure is checked
+type owner struct{}
+type userData struct{}
+
+func placeholder(owner, fsm.Event, userData) (fsm.HandleRetCode, error) {
+	return fsm.ExitOK, nil
+}
+
+func usage(stderr io.Writer) {
+	fmt.Fprintf(stderr, "usage: gofsm validate|render|dump [flags] <spec file>\n")
+	fmt.Fprintf(stderr, "  validate - check the table and its reachability\n")
+	fmt.Fprintf(stderr, "  render   - write the table as diagram, see render -h\n")
+	fmt.Fprintf(stderr, "  dump     - list the states, events and handles\n")
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command, and returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	cmd := args[0]
+	flags := flag.NewFlagSet("gofsm "+cmd, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var format, output *string
+	switch cmd {
+	case "validate", "dump":
+	case "render":
+		format = flags.String("format", "dot", "diagram format, one of dot, mermaid, plantuml")
+		output = flags.String("o", "", "output file, stdout if empty")
+	default:
+		usage(stderr)
+		return exitUsage
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "ERROR: one spec file is required\n")
+		return exitUsage
+	}
+
+	tbl, err := load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %s\n", err)
+		return exitCode(err)
+	}
+
+	analysis := tbl.Analyze()
+	if !analysis.OK() {
+		err := &fsm.AnalysisError{Analysis: analysis, Err: fsmerror.ErrAnalysis}
+		if cmd == "validate" {
+			fmt.Fprintf(stderr, "ERROR: %s\n", err)
+			return exitReachable
+		}
+		fmt.Fprintf(stderr, "WARNING: %s\n", err)
+	}
+
+	switch cmd {
+	case "validate":
+		fmt.Fprintf(stdout, "%s: OK\n", flags.Arg(0))
+		return exitOK
+	case "dump":
+		dump(stdout, tbl)
+		return exitOK
+	}
+
+	writers := map[string]func(io.Writer) error{
+		"dot":      func(w io.Writer) error { return tbl.WriteDOT(w) },
+		"mermaid":  func(w io.Writer) error { return tbl.WriteMermaid(w) },
+		"plantuml": func(w io.Writer) error { return tbl.WritePlantUML(w) },
+	}
+	write, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "ERROR: unknown format %s\n", *format)
+		return exitUsage
+	}
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "ERROR: %s\n", err)
+			return exitError
+		}
+		defer f.Close()
+		w = f
+	}
+	err = write(w)
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %s\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// load reads the specification file, and creates the Table with the placeholder handlers
+func load(path string) (*fsm.Table[owner, userData], error) {
+	spec, err := fsm.ReadTableSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	reg := make(fsm.HandlerRegistry[owner, userData])
+	for _, name := range spec.Handlers() {
+		reg[name] = placeholder
+	}
+	return fsm.NewTableFromSpec(spec, reg)
+}
+
+// dump lists the states, events and handles of the table, in sorted order
+func dump(w io.Writer, tbl *fsm.Table[owner, userData]) {
+	fmt.Fprintf(w, "InitState[%s]\n", tbl.InitState.Name)
+
+	fmt.Fprintf(w, "FinalStates\n")
+	finals := append([]string{}, tbl.FinalStates...)
+	sort.Strings(finals)
+	for _, state := range finals {
+		fmt.Fprintf(w, "  [%s]\n", state)
+	}
+
+	fmt.Fprintf(w, "All States\n")
+	states := make([]fsm.State, 0, len(tbl.States))
+	for state := range tbl.States {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	for _, state := range states {
+		if parent, ok := tbl.Parents[state]; ok {
+			fmt.Fprintf(w, "  [%s] Parent[%s]\n", state.Name, parent.Name)
+		} else {
+			fmt.Fprintf(w, "  [%s]\n", state.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "All Events\n")
+	events := make([]string, 0, len(tbl.Events))
+	for event := range tbl.Events {
+		events = append(events, event.Name)
+	}
+	sort.Strings(events)
+	for _, event := range events {
+		fmt.Fprintf(w, "  [%s]\n", event)
+	}
+
+	// Edges() are sorted by State, Event, guard order and return code
+	state := ""
+	for _, e := range tbl.Edges() {
+		if e.State != state {
+			state = e.State
+			fmt.Fprintf(w, "State[%s]\n", state)
+		}
+		guard := ""
+		if e.Guard != "" {
+			guard = " Guard[" + e.Guard + "]"
+		}
+		fmt.Fprintf(w, "  Event[%s]%s Func[%s] Return code[%d] Next State[%s]\n",
+			e.Event, guard, e.Handle, e.RetCode, e.Next)
+	}
+}
+
+// exitCode returns the exit code for the error
+func exitCode(err error) int {
+	var (
+		conflict *fsm.StateEventConflictError
+		dupErr   *fsm.HandleRetCodeDupError
+		undefH   *fsm.UndefinedHandle
+		analysis *fsm.AnalysisError
+	)
+	switch {
+	case errors.As(err, &conflict):
+		return exitConflict
+	case errors.As(err, &dupErr):
+		return exitDupCode
+	case errors.As(err, &undefH):
+		return exitNoHandle
+	case errors.As(err, &analysis):
+		return exitReachable
+	}
+	return exitError
+}
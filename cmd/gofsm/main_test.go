@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// door is the valid specification
+const door = `initState: Closed
+finalStates: [Locked]
+states:
+  - state: Closed
+    events:
+      - event: Open
+        func: OpenDoor
+        candList: [Opened, Closed]
+      - event: Lock
+        func: LockDoor
+        candList: [Locked, Closed]
+  - state: Opened
+    events:
+      - event: Open
+        func: OpenDoor
+        candList: [Opened]
+      - event: Lock
+        func: LockDoor
+        candList: [Locked, Opened]
+`
+
+// unreachable is the State not reachable from InitState
+const unreachable = `  - state: Broken
+    events:
+      - event: Open
+        func: OpenDoor
+        candList: [Broken]
+`
+
+// writeSpec writes the specification to the temporary file, and returns its path
+func writeSpec(t *testing.T, spec string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		spec string
+		code int
+	}{
+		{"ok", []string{"validate"}, door, exitOK},
+		{"render", []string{"render", "-format", "mermaid"}, door, exitOK},
+		{"not found", []string{"validate", filepath.Join(t.TempDir(), "none.yaml")}, "", exitError},
+		{"invalid spec", []string{"validate"}, "initState: [", exitError},
+		{"no command", []string{}, "", exitUsage},
+		{"unknown command", []string{"lint"}, door, exitUsage},
+		{"no file", []string{"validate"}, "", exitUsage},
+		{"unknown format", []string{"render", "-format", "svg"}, door, exitUsage},
+		{"conflict", []string{"validate"}, door + `      - event: Open
+        func: OpenDoor
+        candList: [Opened]
+`, exitConflict},
+		{"duplicated return code", []string{"validate"}, door + `      - event: Close
+        func: CloseDoor
+        candList: [Closed]
+        candMap:
+          0: Opened
+`, exitDupCode},
+		{"undefined handle", []string{"validate"}, strings.Replace(door, "candList: [Opened, Closed]", "candList: [Opened, Closed, Broken]", 1) +
+			"  - state: Broken\n", exitNoHandle},
+		{"unreachable", []string{"validate"}, door + unreachable, exitReachable},
+		{"unreachable warning", []string{"dump"}, door + unreachable, exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.spec != "" {
+				args = append(append([]string{}, args...), writeSpec(t, tt.spec))
+			}
+			var stdout, stderr strings.Builder
+			if code := run(args, &stdout, &stderr); code != tt.code {
+				t.Fatalf("exit code %d, expected %d\n%s", code, tt.code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestDumpSorted(t *testing.T) {
+	path := writeSpec(t, door)
+	var first strings.Builder
+	for i := 0; i < 10; i++ {
+		var stdout, stderr strings.Builder
+		if code := run([]string{"dump", path}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("exit code %d\n%s", code, stderr.String())
+		}
+		if i == 0 {
+			first.WriteString(stdout.String())
+			continue
+		}
+		if stdout.String() != first.String() {
+			t.Fatalf("dump not stable\n%s\n%s", first.String(), stdout.String())
+		}
+	}
+
+	out := first.String()
+	if !strings.Contains(out, "All States\n  [Closed]\n  [Locked]\n  [Opened]\n") ||
+		!strings.Contains(out, "All Events\n  [Lock]\n  [Open]\n") ||
+		strings.Index(out, "State[Closed]") > strings.Index(out, "State[Opened]") {
+		t.Fatalf("dump not sorted\n%s", out)
+	}
+}
@@ -0,0 +1,146 @@
+package fsm
+
+import (
+	"context"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// FSM State Event Handle Function, receiving the context of TransitContext()
+//
+//	HandleRetCode - Handle return code
+//	error - handler error, if any
+type HandleFuncCtx[OWNER any, USERDATA any] func(ctx context.Context, Owner OWNER, event Event, UserData USERDATA) (HandleRetCode, error)
+
+// FSM State Event Guard Function, receiving the context of TransitContext()
+// returns true if the handle accepts the event
+type GuardFuncCtx[OWNER any, USERDATA any] func(ctx context.Context, Owner OWNER, event Event, UserData USERDATA) bool
+
+// FSM State Entry/Exit Action Function, receiving the context of TransitContext()
+type StateFuncCtx[OWNER any, USERDATA any] func(ctx context.Context, Owner OWNER, state State, event Event, UserData USERDATA) error
+
+// Func Conflict Error, both the function and its context-aware variant are given
+type FuncConflictError struct {
+	State string
+	Event string
+	Func  string // "Func", "Guard", "OnEnter" or "OnExit"
+	Err   error
+}
+
+func (e *FuncConflictError) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event + ", Func=" + e.Func
+}
+
+func (e *FuncConflictError) Unwrap() error { return e.Err }
+
+// Transition Canceled Error, the context was done before the State changed
+// the State is not changed, and no Entry/Exit Action is called
+type TransitCanceled struct {
+	State string
+	Event string
+	Cause error // ctx.Err()
+	Err   error
+}
+
+func (e *TransitCanceled) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event + ", Cause=" + e.Cause.Error()
+}
+
+func (e *TransitCanceled) Unwrap() error { return e.Err }
+
+// canceled returns *TransitCanceled if ctx is done, nil otherwise
+func canceled(ctx context.Context, state string, ev string) error {
+	if err := ctx.Err(); err != nil {
+		return &TransitCanceled{State: state, Event: ev, Cause: err, Err: fsmerror.ErrTransitCanceled}
+	}
+	return nil
+}
+
+// checkFuncs checks the context-aware functions of d are not given with their legacy ones
+func checkFuncs[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA]) error {
+	conflict := func(state string, event string, f string) error {
+		return &FuncConflictError{State: state, Event: event, Func: f, Err: fsmerror.ErrDupHandle}
+	}
+
+	for _, state := range d.States {
+		if state.OnEnter != nil && state.OnEnterCtx != nil {
+			return conflict(state.State, "", "OnEnter")
+		}
+		if state.OnExit != nil && state.OnExitCtx != nil {
+			return conflict(state.State, "", "OnExit")
+		}
+		for _, event := range state.Events {
+			if event.Func != nil && event.FuncCtx != nil {
+				return conflict(state.State, event.Event, "Func")
+			}
+			if event.Guard != nil && event.GuardCtx != nil {
+				return conflict(state.State, event.Event, "Guard")
+			}
+		}
+	}
+	return nil
+}
+
+// call runs the handle function
+func (h *Handle[OWNER, USERDATA]) call(ctx context.Context, owner OWNER, event Event, userData USERDATA) (HandleRetCode, error) {
+	if h.FuncCtx != nil {
+		return h.FuncCtx(ctx, owner, event, userData)
+	}
+	return h.Func(owner, event, userData)
+}
+
+// guarded reports the handle has the guard
+func (h *Handle[OWNER, USERDATA]) guarded() bool {
+	return h.Guard != nil || h.GuardCtx != nil
+}
+
+// accepts reports the guard of the handle accepts the event, true if not guarded
+func (h *Handle[OWNER, USERDATA]) accepts(ctx context.Context, owner OWNER, event Event, userData USERDATA) bool {
+	switch {
+	case h.GuardCtx != nil:
+		return h.GuardCtx(ctx, owner, event, userData)
+	case h.Guard != nil:
+		return h.Guard(owner, event, userData)
+	}
+	return true
+}
+
+// enter runs the Entry Action, nil action does nothing
+func (a *StateAction[OWNER, USERDATA]) enter(ctx context.Context, owner OWNER, state State, event Event, userData USERDATA) error {
+	switch {
+	case a == nil:
+	case a.OnEnterCtx != nil:
+		return a.OnEnterCtx(ctx, owner, state, event, userData)
+	case a.OnEnter != nil:
+		return a.OnEnter(owner, state, event, userData)
+	}
+	return nil
+}
+
+// exit runs the Exit Action, nil action does nothing
+func (a *StateAction[OWNER, USERDATA]) exit(ctx context.Context, owner OWNER, state State, event Event, userData USERDATA) error {
+	switch {
+	case a == nil:
+	case a.OnExitCtx != nil:
+		return a.OnExitCtx(ctx, owner, state, event, userData)
+	case a.OnExit != nil:
+		return a.OnExit(owner, state, event, userData)
+	}
+	return nil
+}
+
+// TransitContext does FSM like TransitWithData(), with the context
+// ctx is passed to the context-aware Guards, handles and Entry/Exit Actions,
+// and to the Listeners as TransitInfo.Context
+// if ctx is done before the handle or after the handle returned, the transition is canceled,
+// the State is not changed, no Entry/Exit Action is called, and *TransitCanceled is returned
+// once the State starts changing, the transition completes regardless of ctx
+// the events deferred by the State keep ctx without its cancellation, see context.WithoutCancel()
+func (e *Entry[OWNER, USERDATA]) TransitContext(ctx context.Context, ev string, userData USERDATA) (State, bool, error) {
+	return e.transit(ctx, ev, userData, 0)
+}
+
+// PostContext queues the event like Post(), processed by Run() with ctx
+func (e *Entry[OWNER, USERDATA]) PostContext(ctx context.Context, ev string, userData USERDATA) {
+	e.queue.push(posted[USERDATA]{ctx: ctx, event: ev, data: userData})
+}
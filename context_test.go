@@ -0,0 +1,133 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+type traceKey struct{}
+
+// traced returns the trace ID of ctx
+func traced(ctx context.Context) string {
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+func TestTransitContext(t *testing.T) {
+	var trace []string
+	var cancel context.CancelFunc
+	handle := func(ctx context.Context, d *door, ev Event, n *int) (HandleRetCode, error) {
+		trace = append(trace, "func "+traced(ctx))
+		if *n < 0 {
+			cancel()
+		}
+		return ExitOK, nil
+	}
+	guard := func(ctx context.Context, d *door, ev Event, n *int) bool {
+		trace = append(trace, "guard "+traced(ctx))
+		return true
+	}
+	enter := func(ctx context.Context, d *door, s State, ev Event, n *int) error {
+		trace = append(trace, "enter "+traced(ctx))
+		return nil
+	}
+	type E = EventDesc[*door, *int]
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "A",
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []E{{Event: "T", GuardCtx: guard, FuncCtx: handle, CandList: []string{"B"}}}},
+			{State: "B", OnEnterCtx: enter, Events: []E{{Event: "T", FuncCtx: handle, CandList: []string{"A"}}}},
+		},
+	})
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	ctx := context.WithValue(context.Background(), traceKey{}, "t1")
+
+	if state, _, err := d.entry.TransitContext(ctx, "T", code(0)); err != nil || state.Name != "B" {
+		t.Fatalf("state %s error %v, expected B", state.Name, err)
+	}
+	if len(trace) != 3 || trace[0] != "guard t1" || trace[1] != "func t1" || trace[2] != "enter t1" {
+		t.Fatalf("trace %v, expected t1 passed to guard, func and enter", trace)
+	}
+
+	// canceled before the handle, the State is not changed
+	trace = nil
+	canceled, c := context.WithCancel(ctx)
+	c()
+	state, eot, err := d.entry.TransitContext(canceled, "T", code(0))
+	var tc *TransitCanceled
+	if !errors.As(err, &tc) || !errors.Is(tc.Cause, context.Canceled) || state.Name != "B" || eot || len(trace) != 0 {
+		t.Fatalf("state %s eot %v error %v trace %v, expected canceled in B", state.Name, eot, err, trace)
+	}
+
+	// canceled by the handle, stays in the current State
+	canceled, cancel = context.WithCancel(ctx)
+	defer cancel()
+	state, _, err = d.entry.TransitContext(canceled, "T", code(-1))
+	if !errors.As(err, &tc) || state.Name != "B" {
+		t.Fatalf("state %s error %v, expected canceled in B", state.Name, err)
+	}
+
+	// the ctx handle is called without TransitContext()
+	if state, _, err := d.entry.TransitWithData("T", code(0)); err != nil || state.Name != "A" {
+		t.Fatalf("state %s error %v, expected A", state.Name, err)
+	}
+}
+
+func TestFuncConflict(t *testing.T) {
+	handle := func(ctx context.Context, d *door, ev Event, n *int) (HandleRetCode, error) { return ExitOK, nil }
+	_, err := NewTable(&TableDesc[*door, *int]{
+		InitState: "A",
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []EventDesc[*door, *int]{{Event: "T", Func: retCode, FuncCtx: handle, CandList: []string{"A"}}}},
+		},
+	})
+	var fc *FuncConflictError
+	if !errors.As(err, &fc) {
+		t.Fatalf("error %v, expected *FuncConflictError", err)
+	}
+}
+
+func TestAnyStateContextAction(t *testing.T) {
+	enter := func(ctx context.Context, d *door, s State, ev Event, n *int) error { return nil }
+	type E = EventDesc[*door, *int]
+	_, err := NewTable(&TableDesc[*door, *int]{
+		InitState: "A",
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []E{{Event: "T", Func: retCode, CandList: []string{"A"}}}},
+			{State: AnyState, OnEnterCtx: enter, Events: []E{{Event: "T", Func: retCode, CandList: []string{"A"}}}},
+		},
+	})
+	var w *AmbiguousWildcard
+	if !errors.As(err, &w) {
+		t.Fatalf("error %v, expected *AmbiguousWildcard", err)
+	}
+}
+
+// traceHandler records the trace ID of the context of every record
+type traceHandler struct {
+	slog.Handler
+	ids *[]string
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	*h.ids = append(*h.ids, traced(ctx))
+	return nil
+}
+
+func TestLogHandlerContext(t *testing.T) {
+	var ids []string
+	tbl := newTestTable(t, doorDesc())
+	tbl.AddLogHandler(traceHandler{Handler: slog.Default().Handler(), ids: &ids})
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "t2")
+	d.entry.TransitContext(ctx, "Open", code(0))
+	d.entry.TransitContext(ctx, "Kick", code(0))
+	if len(ids) != 2 || ids[0] != "t2" || ids[1] != "t2" {
+		t.Fatalf("trace ids %v, expected t2 of the transit and the invalid event", ids)
+	}
+}
@@ -0,0 +1,191 @@
+package fsm
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// transitions taken by the Entries of the Table, counted per return code of the handle
+type coverage[OWNER any, USERDATA any] struct {
+	mu   sync.Mutex
+	hits map[*Handle[OWNER, USERDATA]]map[HandleRetCode]uint64
+}
+
+// EnableCoverage starts counting the transitions taken by all Entries of the Table,
+// see Coverage()
+// MUST be called before any transition
+func (tbl *Table[OWNER, USERDATA]) EnableCoverage() {
+	if tbl.cov == nil {
+		tbl.cov = &coverage[OWNER, USERDATA]{}
+	}
+	tbl.ResetCoverage()
+}
+
+// ResetCoverage clears the counts of the transitions
+func (tbl *Table[OWNER, USERDATA]) ResetCoverage() {
+	if tbl.cov == nil {
+		return
+	}
+	tbl.cov.mu.Lock()
+	defer tbl.cov.mu.Unlock()
+	tbl.cov.hits = make(map[*Handle[OWNER, USERDATA]]map[HandleRetCode]uint64)
+}
+
+// cover counts the transition by the return code of the handle, if the coverage is enabled
+func (tbl *Table[OWNER, USERDATA]) cover(handle *Handle[OWNER, USERDATA], retCode HandleRetCode) {
+	if tbl.cov == nil {
+		return
+	}
+	tbl.cov.mu.Lock()
+	defer tbl.cov.mu.Unlock()
+	codes, ok := tbl.cov.hits[handle]
+	if !ok {
+		codes = make(map[HandleRetCode]uint64)
+		tbl.cov.hits[handle] = codes
+	}
+	codes[retCode]++
+}
+
+// Transition coverage of the Edge
+type EdgeCoverage struct {
+	Edge
+	Hits uint64 // number of the transitions taken
+}
+
+// Transition coverage report of the Table
+// the transitions to the next States of ErrMap and PanicState are not counted
+type CoverageReport struct {
+	Covered   []EdgeCoverage // Edges taken at least once, in order of Edges()
+	Uncovered []Edge         // Edges never taken, in order of Edges()
+	Percent   float64        // covered Edges per all Edges, 100 if the Table has no Edge
+}
+
+// Coverage returns the coverage report of the transitions taken since EnableCoverage(),
+// nil if the coverage is not enabled
+func (tbl *Table[OWNER, USERDATA]) Coverage() *CoverageReport {
+	if tbl.cov == nil {
+		return nil
+	}
+	tbl.cov.mu.Lock()
+	defer tbl.cov.mu.Unlock()
+
+	hits := make(map[Edge]uint64)
+	for state, events := range tbl.Handles {
+		for event, handle := range events {
+			for ; handle != nil; handle = handle.Alt {
+				for code, next := range handle.CandMap {
+					e := Edge{
+						State:   state.Name,
+						Event:   event.Name,
+						Guard:   handle.GuardName,
+						Handle:  handle.Name,
+						RetCode: code,
+						Next:    next,
+					}
+					hits[e] += tbl.cov.hits[handle][code]
+				}
+			}
+		}
+	}
+
+	report := &CoverageReport{
+		Covered:   make([]EdgeCoverage, 0),
+		Uncovered: make([]Edge, 0),
+	}
+	edges := tbl.Edges()
+	for _, e := range edges {
+		if hits[e] > 0 {
+			report.Covered = append(report.Covered, EdgeCoverage{Edge: e, Hits: hits[e]})
+		} else {
+			report.Uncovered = append(report.Uncovered, e)
+		}
+	}
+	report.Percent = 100
+	if len(edges) > 0 {
+		report.Percent = float64(len(report.Covered)) * 100 / float64(len(edges))
+	}
+	return report
+}
+
+// hits returns the number of the transitions taken indexed by Edge
+func (r *CoverageReport) hits() map[Edge]uint64 {
+	hits := make(map[Edge]uint64, len(r.Covered))
+	for _, c := range r.Covered {
+		hits[c.Edge] = c.Hits
+	}
+	return hits
+}
+
+// WriteText writes the report, one Edge per line, covered Edges first
+//
+//	coverage: 75.0% (3/4)
+//	+ Closed -> Opened : Open / OpenDoor [0] (12)
+//	- Opened -> Broken : Kick / KickDoor [0]
+func (r *CoverageReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	total := len(r.Covered) + len(r.Uncovered)
+	b.WriteString("coverage: " + strconv.FormatFloat(r.Percent, 'f', 1, 64) + "% (" +
+		strconv.Itoa(len(r.Covered)) + "/" + strconv.Itoa(total) + ")\n")
+	for _, c := range r.Covered {
+		b.WriteString("+ " + c.State + " -> " + c.Next + " : " + c.Label() +
+			" (" + strconv.FormatUint(c.Hits, 10) + ")\n")
+	}
+	for _, e := range r.Uncovered {
+		b.WriteString("- " + e.State + " -> " + e.Next + " : " + e.Label() + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Edge record of the JSON coverage report
+type edgeRecord struct {
+	State   string `json:"state"`
+	Event   string `json:"event"`
+	Guard   string `json:"guard,omitempty"`
+	Handle  string `json:"handle"`
+	RetCode int    `json:"retCode"`
+	Next    string `json:"next"`
+	Hits    uint64 `json:"hits"`
+}
+
+func newEdgeRecord(e Edge, hits uint64) edgeRecord {
+	return edgeRecord{
+		State:   e.State,
+		Event:   e.Event,
+		Guard:   e.Guard,
+		Handle:  e.Handle,
+		RetCode: int(e.RetCode),
+		Next:    e.Next,
+		Hits:    hits,
+	}
+}
+
+// WriteJSON writes the report as JSON
+//
+//	{"percent": 75, "covered": [{"state": "Closed", ..., "hits": 12}], "uncovered": [...]}
+func (r *CoverageReport) WriteJSON(w io.Writer) error {
+	doc := struct {
+		Percent   float64      `json:"percent"`
+		Covered   []edgeRecord `json:"covered"`
+		Uncovered []edgeRecord `json:"uncovered"`
+	}{
+		Percent:   r.Percent,
+		Covered:   make([]edgeRecord, 0, len(r.Covered)),
+		Uncovered: make([]edgeRecord, 0, len(r.Uncovered)),
+	}
+	for _, c := range r.Covered {
+		doc.Covered = append(doc.Covered, newEdgeRecord(c.Edge, c.Hits))
+	}
+	for _, e := range r.Uncovered {
+		doc.Uncovered = append(doc.Uncovered, newEdgeRecord(e, 0))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&doc)
+}
@@ -0,0 +1,150 @@
+package fsm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	tbl := newTestTable(t, doorDesc())
+	if tbl.Coverage() != nil {
+		t.Fatal("coverage not enabled, but reported")
+	}
+	tbl.EnableCoverage()
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	// Closed -Open/0-> Opened -Close/0-> Closed -Open/0-> Opened
+	for _, ev := range []string{"Open", "Close", "Open"} {
+		if _, _, err := d.entry.TransitWithData(ev, code(0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := tbl.Coverage()
+	edges := tbl.Edges()
+	if len(report.Covered) != 2 || len(report.Covered)+len(report.Uncovered) != len(edges) {
+		t.Fatalf("covered %v, uncovered %v", report.Covered, report.Uncovered)
+	}
+	if report.Percent != float64(2)*100/float64(len(edges)) {
+		t.Fatalf("percent %v", report.Percent)
+	}
+	for _, c := range report.Covered {
+		switch {
+		case c.State == "Closed" && c.Next == "Opened" && c.Hits == 2:
+		case c.State == "Opened" && c.Next == "Closed" && c.Hits == 1:
+		default:
+			t.Fatalf("unexpected coverage %+v", c)
+		}
+	}
+
+	tbl.ResetCoverage()
+	if report := tbl.Coverage(); len(report.Covered) != 0 || report.Percent != 0 {
+		t.Fatalf("coverage not reset, %+v", report)
+	}
+}
+
+func TestCoverageWriters(t *testing.T) {
+	tbl := newTestTable(t, doorDesc())
+	tbl.EnableCoverage()
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	if _, _, err := d.entry.TransitWithData("Lock", code(0)); err != nil {
+		t.Fatal(err)
+	}
+	report := tbl.Coverage()
+
+	var text strings.Builder
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	if len(lines) != len(tbl.Edges())+1 || !strings.HasPrefix(lines[0], "coverage: 12.5% (1/8)") {
+		t.Fatalf("unexpected text report\n%s", text.String())
+	}
+	if !strings.HasPrefix(lines[1], "+ Closed -> Locked : Lock / ") || !strings.HasSuffix(lines[1], " (1)") {
+		t.Fatalf("unexpected covered line %q", lines[1])
+	}
+
+	var js strings.Builder
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Percent   float64
+		Covered   []map[string]interface{}
+		Uncovered []map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(js.String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Percent != 12.5 || len(doc.Covered) != 1 || len(doc.Uncovered) != 7 ||
+		doc.Covered[0]["next"] != "Locked" || doc.Covered[0]["hits"] != float64(1) {
+		t.Fatalf("unexpected json report\n%s", js.String())
+	}
+
+	var dot strings.Builder
+	if err := tbl.WriteCoverageDOT(&dot, report); err != nil {
+		t.Fatal(err)
+	}
+	green := 0
+	for _, line := range strings.Split(dot.String(), "\n") {
+		if !strings.Contains(line, " -> \"") || strings.Contains(line, "__start") {
+			continue
+		}
+		covered := strings.Contains(line, `"Closed" -> "Locked"`) && strings.Contains(line, "(1)")
+		switch {
+		case covered && !strings.Contains(line, "color=green"):
+			t.Fatalf("covered edge not green: %s", line)
+		case !covered && !strings.Contains(line, "color=red"):
+			t.Fatalf("uncovered edge not red: %s", line)
+		case covered:
+			green++
+		}
+	}
+	if green != 1 {
+		t.Fatalf("%d covered edges, expected 1\n%s", green, dot.String())
+	}
+}
+
+func TestCoverageNotRouted(t *testing.T) {
+	tbl := newTestTable(t, routeDesc())
+	tbl.EnableCoverage()
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	if _, _, err := d.entry.TransitWithData("T", code(1)); err == nil {
+		t.Fatal("expected error of the handle")
+	}
+	expectState(t, d.entry, "Rejected")
+	if report := tbl.Coverage(); len(report.Covered) != 0 {
+		t.Fatalf("routed transition counted, %+v", report.Covered)
+	}
+
+	tbl = newTestTable(t, panicDesc(PanicRoute, "Failed"))
+	tbl.EnableCoverage()
+	d.entry = tbl.NewEntry(d)
+	if _, _, err := d.entry.TransitWithData("T", code(1)); err == nil {
+		t.Fatal("expected panic error")
+	}
+	expectState(t, d.entry, "Failed")
+	if report := tbl.Coverage(); len(report.Covered) != 0 {
+		t.Fatalf("panic transition counted, %+v", report.Covered)
+	}
+}
+
+func TestCoverageNotCloned(t *testing.T) {
+	tbl := newTestTable(t, doorDesc())
+	tbl.EnableCoverage()
+	clone := tbl.Clone()
+	if clone.Coverage() != nil {
+		t.Fatal("coverage of the Table shared by the clone")
+	}
+
+	d := &door{}
+	d.entry = clone.NewEntry(d)
+	d.entry.TransitWithData("Open", code(0))
+	if len(tbl.cov.hits) != 0 {
+		t.Fatalf("transition of the clone counted by the Table, %v", tbl.cov.hits)
+	}
+}
@@ -0,0 +1,148 @@
+package fsm
+
+import (
+	"context"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// default maximum number of deferred events per Entry
+const DefaultDeferMax = 64
+
+// Event Deferred Error, the event is held by the Entry until the State changes
+type EventDeferred struct {
+	State string
+	Event string
+	Err   error
+}
+
+func (e *EventDeferred) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event
+}
+
+func (e *EventDeferred) Unwrap() error { return e.Err }
+
+// Deferred Event Overflow Error, the event is dropped
+type DeferOverflow struct {
+	State string
+	Event string
+	Err   error
+}
+
+func (e *DeferOverflow) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event
+}
+
+func (e *DeferOverflow) Unwrap() error { return e.Err }
+
+// event held by the Entry
+type deferredEvent[USERDATA any] struct {
+	ctx   context.Context // context of the event, without its cancellation
+	event string
+	data  USERDATA
+}
+
+// deferredIn reports any active leaf State defers the event
+// the State handling the event itself does not defer it, even if its ancestor defers it
+func (tbl *Table[OWNER, USERDATA]) deferredIn(active []State, event Event) bool {
+	for _, leaf := range active {
+		for s, ok := leaf, true; ok; s, ok = tbl.Parents[s] {
+			if _, handled := tbl.Handles[s][event]; handled {
+				break
+			}
+			if _, deferred := tbl.Defers[s][event]; deferred {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deferMax returns the maximum number of deferred events per Entry
+func (tbl *Table[OWNER, USERDATA]) deferMax() int {
+	if tbl.DeferMax <= 0 {
+		return DefaultDeferMax
+	}
+	return tbl.DeferMax
+}
+
+// deferEvent holds the event, or drops it if the Entry holds too many events
+func (e *Entry[OWNER, USERDATA]) deferEvent(ctx context.Context, state string, ev string, userData USERDATA, n *notifier[OWNER, USERDATA]) (State, bool, error) {
+	log := &TrnasitLog{}
+	log.time = e.table.Clock.Now()
+	log.state = state
+	log.event = ev
+	log.next = state
+	log.deferred = true
+
+	var err error
+	e.mu.Lock()
+	if len(e.deferred) >= e.table.deferMax() {
+		err = &DeferOverflow{State: state, Event: ev, Err: fsmerror.ErrDeferOverflow}
+	} else {
+		e.deferred = append(e.deferred, deferredEvent[USERDATA]{ctx: context.WithoutCancel(ctx), event: ev, data: userData})
+		err = &EventDeferred{State: state, Event: ev, Err: fsmerror.ErrEventDeferred}
+	}
+	log.err = err
+	e.appendLog(log)
+	e.mu.Unlock()
+
+	if n != nil {
+		n.info.Err = err
+		n.notify(Listener[OWNER, USERDATA].AfterTransit)
+	}
+	return State{state}, false, err
+}
+
+// replay processes the deferred events not deferred by the current State, in order
+// the held events are checked again from the first one whenever the State changes
+// reports any replayed event changed the State
+func (e *Entry[OWNER, USERDATA]) replay() bool {
+	moved := false
+	for i := 0; ; {
+		e.mu.Lock()
+		if i >= len(e.deferred) {
+			e.mu.Unlock()
+			return moved
+		}
+		d := e.deferred[i]
+		if e.table.deferredIn(e.active, Event{d.event}) {
+			e.mu.Unlock()
+			i++
+			continue
+		}
+		e.deferred = append(e.deferred[:i:i], e.deferred[i+1:]...)
+		before := e.active
+		e.mu.Unlock()
+
+		e.process(d.ctx, d.event, d.data, true)
+
+		e.mu.RLock()
+		changed := !sameStates(before, e.active)
+		e.mu.RUnlock()
+		if changed {
+			moved = true
+			i = 0
+		}
+	}
+}
+
+// sameStates reports a and b have the same States in the same order
+func sameStates(a []State, b []State) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Deferred returns the number of the events held by the Entry
+func (e *Entry[OWNER, USERDATA]) Deferred() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.deferred)
+}
@@ -0,0 +1,61 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// openingTable defers Lock while the door is Opening
+func openingTable(t *testing.T) *Table[*door, *int] {
+	type E = EventDesc[*door, *int]
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "Closed",
+		FinalStates: []string{"Locked"},
+		LogMax:      10,
+		DeferMax:    1,
+		States: []StateDesc[*door, *int]{
+			{State: "Closed", Events: []E{
+				{Event: "Open", Func: retCode, CandList: []string{"Opening"}},
+				{Event: "Lock", Func: retCode, CandList: []string{"Locked"}},
+			}},
+			{State: "Opening", Defer: []string{"Lock"}, Events: []E{
+				{Event: "Open", Func: retCode, CandList: []string{"Opening"}},
+				{Event: "Done", Func: retCode, CandList: []string{"Opened"}},
+			}},
+			{State: "Opened", Events: []E{
+				{Event: "Open", Func: retCode, CandList: []string{"Opened"}},
+				{Event: "Done", Func: retCode, CandList: []string{"Opened"}},
+				{Event: "Lock", Func: retCode, CandList: []string{"Locked"}},
+			}},
+		},
+	})
+}
+
+func TestDeferredReplay(t *testing.T) {
+	d := &door{}
+	d.entry = openingTable(t).NewEntry(d)
+	d.entry.Transit("Open")
+
+	state, eot, err := d.entry.Transit("Lock")
+	var deferred *EventDeferred
+	if !errors.As(err, &deferred) || state.Name != "Opening" || eot || d.entry.Deferred() != 1 {
+		t.Fatalf("state %s eot %v error %v, expected Lock held in Opening", state.Name, eot, err)
+	}
+	var overflow *DeferOverflow
+	if _, _, err := d.entry.Transit("Lock"); !errors.As(err, &overflow) {
+		t.Fatalf("error %v, expected *DeferOverflow over DeferMax", err)
+	}
+
+	// the result is of the Entry after the held Lock is replayed
+	state, eot, err = d.entry.Transit("Done")
+	if err != nil || state.Name != "Locked" || !eot {
+		t.Fatalf("state %s eot %v error %v, expected final Locked", state.Name, eot, err)
+	}
+	if d.entry.Deferred() != 0 {
+		t.Fatalf("%d deferred, expected 0", d.entry.Deferred())
+	}
+	logs := d.entry.LogRecords(2)
+	if logs[0].Event != "Done" || logs[1].Event != "Lock" || !logs[1].Replayed {
+		t.Fatalf("logs %+v, expected Done then replayed Lock", logs)
+	}
+}
@@ -0,0 +1,304 @@
+package fsm
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FSM State Event Transition, one return code of the Handle
+type Edge struct {
+	State   string        // current State
+	Event   string        // Event
+	Guard   string        // Guard, empty if not guarded
+	Handle  string        // Func
+	RetCode HandleRetCode // Func's return code
+	Next    string        // next State for the return code
+}
+
+// Label returns the edge label, "Event / Func [retcode]",
+// or "Event [Guard] / Func [retcode]" if guarded
+func (e Edge) Label() string {
+	event := e.Event
+	if e.Guard != "" {
+		event += " [" + e.Guard + "]"
+	}
+	return event + " / " + e.Handle + " [" + strconv.Itoa(int(e.RetCode)) + "]"
+}
+
+// Edges returns all transitions of the table,
+// sorted by State, Event, order of guarded handles and return code
+func (tbl *Table[OWNER, USERDATA]) Edges() []Edge {
+	type ordered struct {
+		Edge
+		alt int // order of the guarded handle
+	}
+	edges := make([]ordered, 0)
+	for state, events := range tbl.Handles {
+		for event, handle := range events {
+			for alt := 0; handle != nil; alt, handle = alt+1, handle.Alt {
+				for code, next := range handle.CandMap {
+					edges = append(edges, ordered{Edge{
+						State:   state.Name,
+						Event:   event.Name,
+						Guard:   handle.GuardName,
+						Handle:  handle.Name,
+						RetCode: code,
+						Next:    next,
+					}, alt})
+				}
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.State != b.State {
+			return a.State < b.State
+		}
+		if a.Event != b.Event {
+			return a.Event < b.Event
+		}
+		if a.alt != b.alt {
+			return a.alt < b.alt
+		}
+		return a.RetCode < b.RetCode
+	})
+
+	result := make([]Edge, 0, len(edges))
+	for _, e := range edges {
+		result = append(result, e.Edge)
+	}
+	return result
+}
+
+// sortedStates returns all states including the final states and the history targets,
+// sorted by name
+func (tbl *Table[OWNER, USERDATA]) sortedStates() []string {
+	seen := make(map[string]interface{})
+	for state := range tbl.States {
+		seen[state.Name] = nil
+	}
+	for _, events := range tbl.Handles {
+		for _, handle := range events {
+			for ; handle != nil; handle = handle.Alt {
+				for _, next := range handle.CandMap {
+					seen[next] = nil
+				}
+			}
+		}
+	}
+	for _, state := range tbl.FinalStates {
+		seen[state] = nil
+	}
+	if tbl.InitState.Name != "" {
+		seen[tbl.InitState.Name] = nil
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedFinalStates returns the final states, sorted by name
+func (tbl *Table[OWNER, USERDATA]) sortedFinalStates() []string {
+	names := make([]string, 0, len(tbl.FSMap))
+	for name := range tbl.FSMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var diagramIdRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// diagramIds assigns identifiers usable in Mermaid and PlantUML
+// states which are not plain identifiers are declared with aliases,
+// never equal to other states
+func diagramIds(states []string) (map[string]string, []string) {
+	taken := make(map[string]interface{}, len(states))
+	for _, state := range states {
+		taken[state] = nil
+	}
+	ids := make(map[string]string, len(states))
+	aliased := make([]string, 0)
+	for i, state := range states {
+		if diagramIdRe.MatchString(state) {
+			ids[state] = state
+		} else {
+			ids[state] = uniqueId("s"+strconv.Itoa(i), taken)
+			aliased = append(aliased, state)
+		}
+	}
+	return ids, aliased
+}
+
+// uniqueId returns id, or id with the smallest suffix "_n" not in taken, and adds it to taken
+func uniqueId(id string, taken map[string]interface{}) string {
+	unique := id
+	for n := 1; ; n++ {
+		if _, ok := taken[unique]; !ok {
+			break
+		}
+		unique = id + "_" + strconv.Itoa(n)
+	}
+	taken[unique] = nil
+	return unique
+}
+
+// overlay marks the edges of the diagram by the coverage report, nil report marks nothing
+type overlay struct {
+	report *CoverageReport
+	hits   map[Edge]uint64
+}
+
+func newOverlay(report *CoverageReport) *overlay {
+	if report == nil {
+		return &overlay{}
+	}
+	return &overlay{report: report, hits: report.hits()}
+}
+
+// label returns the edge label, with the number of the transitions taken if any report
+func (o *overlay) label(e Edge) string {
+	if o.report == nil {
+		return e.Label()
+	}
+	return e.Label() + " (" + strconv.FormatUint(o.hits[e], 10) + ")"
+}
+
+// style returns the color and whether the edge is dashed, empty color if no report
+func (o *overlay) style(e Edge) (string, bool) {
+	if o.report == nil {
+		return "", false
+	}
+	if _, ok := o.hits[e]; ok {
+		return "green", false
+	}
+	return "red", true
+}
+
+// WriteDOT writes the table as Graphviz DOT digraph
+// final states are drawn with double border
+func (tbl *Table[OWNER, USERDATA]) WriteDOT(w io.Writer) error {
+	return tbl.writeDOT(w, newOverlay(nil))
+}
+
+// WriteCoverageDOT writes the table as WriteDOT(), with the coverage report overlaid
+// every label has the number of the transitions taken, covered edges are green, uncovered edges are red dashed
+func (tbl *Table[OWNER, USERDATA]) WriteCoverageDOT(w io.Writer, report *CoverageReport) error {
+	return tbl.writeDOT(w, newOverlay(report))
+}
+
+func (tbl *Table[OWNER, USERDATA]) writeDOT(w io.Writer, o *overlay) error {
+	var b strings.Builder
+
+	states := tbl.sortedStates()
+	taken := make(map[string]interface{}, len(states))
+	for _, state := range states {
+		taken[state] = nil
+	}
+	start := strconv.Quote(uniqueId("__start", taken))
+
+	b.WriteString("digraph fsm {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=rounded];\n")
+	b.WriteString("\t" + start + " [shape=point];\n")
+	for _, state := range states {
+		if _, ok := tbl.FSMap[state]; ok {
+			b.WriteString("\t" + strconv.Quote(state) + " [peripheries=2];\n")
+		} else {
+			b.WriteString("\t" + strconv.Quote(state) + ";\n")
+		}
+	}
+	b.WriteString("\t" + start + " -> " + strconv.Quote(tbl.InitState.Name) + ";\n")
+	for _, e := range tbl.Edges() {
+		attrs := "label=" + strconv.Quote(o.label(e))
+		if color, dashed := o.style(e); color != "" {
+			attrs += ", color=" + color
+			if dashed {
+				attrs += ", style=dashed"
+			}
+		}
+		b.WriteString("\t" + strconv.Quote(e.State) + " -> " + strconv.Quote(e.Next) +
+			" [" + attrs + "];\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the table as Mermaid stateDiagram-v2
+func (tbl *Table[OWNER, USERDATA]) WriteMermaid(w io.Writer) error {
+	return tbl.writeMermaid(w, newOverlay(nil))
+}
+
+// WriteCoverageMermaid writes the table as WriteMermaid(), with the coverage report overlaid
+// stateDiagram-v2 can not style the transitions, thus every label has the number of the transitions taken
+func (tbl *Table[OWNER, USERDATA]) WriteCoverageMermaid(w io.Writer, report *CoverageReport) error {
+	return tbl.writeMermaid(w, newOverlay(report))
+}
+
+func (tbl *Table[OWNER, USERDATA]) writeMermaid(w io.Writer, o *overlay) error {
+	var b strings.Builder
+
+	ids, aliased := diagramIds(tbl.sortedStates())
+	b.WriteString("stateDiagram-v2\n")
+	for _, state := range aliased {
+		b.WriteString("    state " + strconv.Quote(state) + " as " + ids[state] + "\n")
+	}
+	b.WriteString("    [*] --> " + ids[tbl.InitState.Name] + "\n")
+	for _, e := range tbl.Edges() {
+		b.WriteString("    " + ids[e.State] + " --> " + ids[e.Next] + " : " + o.label(e) + "\n")
+	}
+	for _, state := range tbl.sortedFinalStates() {
+		b.WriteString("    " + ids[state] + " --> [*]\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WritePlantUML writes the table as PlantUML state diagram
+func (tbl *Table[OWNER, USERDATA]) WritePlantUML(w io.Writer) error {
+	return tbl.writePlantUML(w, newOverlay(nil))
+}
+
+// WriteCoveragePlantUML writes the table as WritePlantUML(), with the coverage report overlaid,
+// see WriteCoverageDOT()
+func (tbl *Table[OWNER, USERDATA]) WriteCoveragePlantUML(w io.Writer, report *CoverageReport) error {
+	return tbl.writePlantUML(w, newOverlay(report))
+}
+
+func (tbl *Table[OWNER, USERDATA]) writePlantUML(w io.Writer, o *overlay) error {
+	var b strings.Builder
+
+	ids, aliased := diagramIds(tbl.sortedStates())
+	b.WriteString("@startuml\n")
+	for _, state := range aliased {
+		b.WriteString("state " + strconv.Quote(state) + " as " + ids[state] + "\n")
+	}
+	b.WriteString("[*] --> " + ids[tbl.InitState.Name] + "\n")
+	for _, e := range tbl.Edges() {
+		arrow := " --> "
+		if color, dashed := o.style(e); color != "" {
+			arrow = " -[#" + color
+			if dashed {
+				arrow += ",dashed"
+			}
+			arrow += "]-> "
+		}
+		b.WriteString(ids[e.State] + arrow + ids[e.Next] + " : " + o.label(e) + "\n")
+	}
+	for _, state := range tbl.sortedFinalStates() {
+		b.WriteString(ids[state] + " --> [*]\n")
+	}
+	b.WriteString("@enduml\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,149 @@
+package fsm
+
+import (
+	"strings"
+	"testing"
+)
+
+// aliasTable has the state needing the alias, and the states named like the aliases
+func aliasTable(t *testing.T) *Table[*door, *int] {
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "my state",
+		FinalStates: []string{"s1"},
+		States: []StateDesc[*door, *int]{
+			{State: "my state", Events: []EventDesc[*door, *int]{{Event: "Go", Name: "Go", Func: retCode, CandList: []string{"s1"}}}},
+			{State: "__start", Events: []EventDesc[*door, *int]{{Event: "Go", Name: "Go", Func: retCode, CandList: []string{"s1"}}}},
+		},
+	})
+}
+
+func TestWriteMermaidAlias(t *testing.T) {
+	var b strings.Builder
+	if err := aliasTable(t).WriteMermaid(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `stateDiagram-v2
+    state "my state" as s1_1
+    [*] --> s1_1
+    __start --> s1 : Go / Go [0]
+    s1_1 --> s1 : Go / Go [0]
+    s1 --> [*]
+`
+	if b.String() != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
+
+func TestWritePlantUMLAlias(t *testing.T) {
+	var b strings.Builder
+	if err := aliasTable(t).WritePlantUML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "state \"my state\" as s1_1\n") ||
+		!strings.Contains(b.String(), "s1_1 --> s1 : Go / Go [0]\n") {
+		t.Fatalf("alias of \"my state\" not unique\n%s", b.String())
+	}
+}
+
+func TestWriteDOTStart(t *testing.T) {
+	var b strings.Builder
+	if err := aliasTable(t).WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\t\"__start_1\" [shape=point];\n") ||
+		!strings.Contains(b.String(), "\t\"__start_1\" -> \"my state\";\n") {
+		t.Fatalf("start node not unique\n%s", b.String())
+	}
+}
+
+// keyed is the guard of the Lock, accepts if the event data is given
+func keyed(d *door, ev Event, n *int) bool {
+	return n != nil
+}
+
+// diagramTable returns the door with the named handles, and the guarded Lock of Closed
+func diagramTable(t *testing.T) *Table[*door, *int] {
+	desc := doorDesc()
+	for i := range desc.States {
+		for j := range desc.States[i].Events {
+			desc.States[i].Events[j].Name = desc.States[i].Events[j].Event
+		}
+	}
+	desc.States[0].Events = append([]EventDesc[*door, *int]{
+		{Event: "Lock", Name: "LockKey", Guard: keyed, Func: retCode, CandList: []string{"Locked"}},
+	}, desc.States[0].Events...)
+	return newTestTable(t, desc)
+}
+
+// expectDiagram fails the test if write does not write the expected diagram,
+// the same on every call
+func expectDiagram(t *testing.T, write func(b *strings.Builder) error, expected string) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		var b strings.Builder
+		if err := write(&b); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != expected {
+			t.Fatalf("call %d, got\n%s\nexpected\n%s", i, b.String(), expected)
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	tbl := diagramTable(t)
+	expectDiagram(t, func(b *strings.Builder) error { return tbl.WriteDOT(b) }, `digraph fsm {
+	rankdir=LR;
+	node [shape=box, style=rounded];
+	"__start" [shape=point];
+	"Closed";
+	"Locked" [peripheries=2];
+	"Opened";
+	"__start" -> "Closed";
+	"Closed" -> "Closed" [label="Close / Close [0]"];
+	"Closed" -> "Locked" [label="Lock [github.com/HaesungSeo/goFSM/v2.keyed] / LockKey [0]"];
+	"Closed" -> "Locked" [label="Lock / Lock [0]"];
+	"Closed" -> "Closed" [label="Lock / Lock [1]"];
+	"Closed" -> "Opened" [label="Open / Open [0]"];
+	"Closed" -> "Closed" [label="Open / Open [1]"];
+	"Opened" -> "Closed" [label="Close / Close [0]"];
+	"Opened" -> "Opened" [label="Close / Close [1]"];
+	"Opened" -> "Opened" [label="Open / Open [0]"];
+}
+`)
+}
+
+func TestWriteMermaid(t *testing.T) {
+	tbl := diagramTable(t)
+	expectDiagram(t, func(b *strings.Builder) error { return tbl.WriteMermaid(b) }, `stateDiagram-v2
+    [*] --> Closed
+    Closed --> Closed : Close / Close [0]
+    Closed --> Locked : Lock [github.com/HaesungSeo/goFSM/v2.keyed] / LockKey [0]
+    Closed --> Locked : Lock / Lock [0]
+    Closed --> Closed : Lock / Lock [1]
+    Closed --> Opened : Open / Open [0]
+    Closed --> Closed : Open / Open [1]
+    Opened --> Closed : Close / Close [0]
+    Opened --> Opened : Close / Close [1]
+    Opened --> Opened : Open / Open [0]
+    Locked --> [*]
+`)
+}
+
+func TestWritePlantUML(t *testing.T) {
+	tbl := diagramTable(t)
+	expectDiagram(t, func(b *strings.Builder) error { return tbl.WritePlantUML(b) }, `@startuml
+[*] --> Closed
+Closed --> Closed : Close / Close [0]
+Closed --> Locked : Lock [github.com/HaesungSeo/goFSM/v2.keyed] / LockKey [0]
+Closed --> Locked : Lock / Lock [0]
+Closed --> Closed : Lock / Lock [1]
+Closed --> Opened : Open / Open [0]
+Closed --> Closed : Open / Open [1]
+Opened --> Closed : Close / Close [0]
+Opened --> Opened : Close / Close [1]
+Opened --> Opened : Open / Open [0]
+Locked --> [*]
+@enduml
+`)
+}
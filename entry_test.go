@@ -0,0 +1,125 @@
+package fsm
+
+import (
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+// toggleTable moves A and B by T, the handle stores the event data in the Entry
+func toggleTable(t *testing.T) *Table[*door, *int] {
+	toggle := func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		d.entry.Set("n", *n)
+		if v, ok := d.entry.Get("n").(int); !ok || v != *n {
+			t.Errorf("Get n=%v, expected %d", v, *n)
+		}
+		return ExitOK, nil
+	}
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "A",
+		LogMax:    8,
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []EventDesc[*door, *int]{{Event: "T", Func: toggle, CandList: []string{"B"}}}},
+			{State: "B", Events: []EventDesc[*door, *int]{{Event: "T", Func: toggle, CandList: []string{"A"}}}},
+		},
+	})
+}
+
+// quiet redirects stdout while f runs, for PrintLog()
+func quiet(t *testing.T, f func()) {
+	t.Helper()
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, r)
+		close(done)
+	}()
+	os.Stdout = w
+	defer func() {
+		w.Close()
+		<-done
+		os.Stdout = stdout
+	}()
+	f()
+}
+
+func TestSyncEntryConcurrentTransit(t *testing.T) {
+	const workers, rounds = 8, 200
+
+	d := &door{}
+	d.entry = toggleTable(t).NewSyncEntry(d)
+
+	quiet(t, func() {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < rounds; j++ {
+					if _, _, err := d.entry.TransitWithData("T", code(j)); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+
+		// readers and writers racing with the transitions
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				d.entry.Set("x", j)
+				_ = d.entry.Get("n")
+				_ = d.entry.CurrentState()
+				if _, err := d.entry.Snapshot(); err != nil {
+					t.Error(err)
+					return
+				}
+				if j%20 == 0 {
+					d.entry.PrintLog(2)
+				}
+			}
+		}()
+		wg.Wait()
+	})
+
+	// every transition toggled the State once
+	expectState(t, d.entry, "A")
+	if n := len(d.entry.LogRecords(0)); n != 8 {
+		t.Fatalf("%d logs, expected LogMax", n)
+	}
+	if v := d.entry.Get("x"); v != rounds-1 {
+		t.Fatalf("x=%v, expected %d", v, rounds-1)
+	}
+}
+
+func TestSyncEntrySnapshotConsistent(t *testing.T) {
+	d := &door{}
+	d.entry = toggleTable(t).NewSyncEntry(d)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 500; j++ {
+			d.entry.TransitWithData("T", code(j))
+		}
+	}()
+	for j := 0; j < 200; j++ {
+		snap, err := d.entry.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the last log always leads to the captured State
+		if n := len(snap.Logs); n > 0 && snap.Logs[n-1].Next != snap.State {
+			t.Fatalf("snapshot state %s, last log next %s", snap.State, snap.Logs[n-1].Next)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,133 @@
+package fsm
+
+import (
+	"errors"
+	"reflect"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// FSM Error Route, the next State for the error returned by the handle
+// give either of Is or As
+//
+//	var ErrBadRequest = errors.New("bad request")
+//
+//	{Is: ErrBadRequest, Next: "Rejected"},
+//	{As: new(*net.OpError), Next: "Offline"},
+type ErrorRoute struct {
+	Is   error       // target of errors.Is()
+	As   interface{} // target of errors.As(), pointer to the error type or interface
+	Next string      // next State, may be the history target
+}
+
+// match reports the error matches the route
+// As is not written, so the route is safe to share between Entries
+func (r *ErrorRoute) match(err error) bool {
+	if r.Is != nil {
+		return errors.Is(err, r.Is)
+	}
+	target := reflect.New(reflect.TypeOf(r.As).Elem())
+	return errors.As(err, target.Interface())
+}
+
+// Invalid Error Route Error
+type InvalidErrorRoute struct {
+	State  string // State of the route, empty if given by TableDesc
+	Event  string
+	Next   string
+	Reason string
+	Err    error
+}
+
+func (e *InvalidErrorRoute) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event +
+		", Next=" + e.Next + ", Reason=" + e.Reason
+}
+
+func (e *InvalidErrorRoute) Unwrap() error { return e.Err }
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// checkErrorRoute checks the route has a valid target of errors.Is() or errors.As()
+func checkErrorRoute(state string, event string, r *ErrorRoute) error {
+	invalid := func(reason string) error {
+		return &InvalidErrorRoute{State: state, Event: event, Next: r.Next, Reason: reason, Err: fsmerror.ErrInvalidErrorRoute}
+	}
+
+	switch {
+	case r.Is != nil && r.As != nil:
+		return invalid("both Is and As")
+	case r.Is != nil:
+		return nil
+	case r.As == nil:
+		return invalid("neither Is nor As")
+	}
+	typ := reflect.TypeOf(r.As)
+	if typ.Kind() != reflect.Ptr || reflect.ValueOf(r.As).IsNil() {
+		return invalid("As is not a non-nil pointer")
+	}
+	if elem := typ.Elem(); elem.Kind() != reflect.Interface && !elem.Implements(errorType) {
+		return invalid("As is not a pointer to the error type or interface")
+	}
+	return nil
+}
+
+// nexts returns the next States of the handle, CandList, CandMap and ErrMap
+func (event *EventDesc[OWNER, USERDATA]) nexts() []string {
+	targets := append([]string{}, event.CandList...)
+	for _, nstate := range event.CandMap {
+		targets = append(targets, nstate)
+	}
+	for _, r := range event.ErrMap {
+		targets = append(targets, r.Next)
+	}
+	return targets
+}
+
+// checkErrorRoutes checks ErrMap of TableDesc, the next States are checked like PanicState
+func (tbl *Table[OWNER, USERDATA]) checkErrorRoutes(d *TableDesc[OWNER, USERDATA]) error {
+	for i := range d.ErrMap {
+		r := &d.ErrMap[i]
+		if err := checkErrorRoute("", "", r); err != nil {
+			return err
+		}
+		invalid := func(reason string) error {
+			return &InvalidErrorRoute{Next: r.Next, Reason: reason, Err: fsmerror.ErrInvalidErrorRoute}
+		}
+		if _, ok := historyGroup(r.Next); ok {
+			return invalid("history target")
+		}
+		if _, ok := tbl.States[State{r.Next}]; !ok {
+			return invalid("undefined next state")
+		}
+		if tbl.inRegion(State{r.Next}) {
+			return invalid("next state in parallel region")
+		}
+		if err := tbl.checkEntrance(State{r.Next}); err != nil {
+			return err
+		}
+	}
+	for _, state := range d.States {
+		for _, event := range state.Events {
+			for i := range event.ErrMap {
+				if err := checkErrorRoute(state.State, event.Event, &event.ErrMap[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// route returns the next State of the first route matching the error,
+// the routes of the handle first, then of the Table
+func (tbl *Table[OWNER, USERDATA]) route(handle *Handle[OWNER, USERDATA], err error) (string, bool) {
+	for _, routes := range [][]ErrorRoute{handle.ErrMap, tbl.ErrMap} {
+		for i := range routes {
+			if routes[i].match(err) {
+				return routes[i].Next, true
+			}
+		}
+	}
+	return "", false
+}
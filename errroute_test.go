@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errBadRequest = errors.New("bad request")
+
+// codeError is the error of the handle, matched by ErrorRoute.As
+type codeError struct {
+	code int
+}
+
+func (e *codeError) Error() string { return fmt.Sprintf("code %d", e.code) }
+
+// routeDesc returns the Table routing the errors of T in A,
+// the event data selects the error of the handle
+func routeDesc() *TableDesc[*door, *int] {
+	handle := func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		switch *n {
+		case 1:
+			return ExitOK, fmt.Errorf("wrapped: %w", errBadRequest)
+		case 2:
+			return ExitOK, &codeError{2}
+		case 3:
+			return ExitOK, errors.New("other")
+		}
+		return ExitOK, nil
+	}
+	type E = EventDesc[*door, *int]
+	return &TableDesc[*door, *int]{
+		InitState:   "A",
+		FinalStates: []string{"Rejected", "Coded", "Failed"},
+		ErrMap:      []ErrorRoute{{As: new(error), Next: "Failed"}},
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []E{{Event: "T", Func: handle, CandList: []string{"B"}, ErrMap: []ErrorRoute{
+				{Is: errBadRequest, Next: "Rejected"},
+				{As: new(*codeError), Next: "Coded"},
+			}}}},
+			{State: "B", Events: []E{{Event: "T", Func: handle, CandList: []string{"A"}}}},
+			{State: "Failed"},
+		},
+	}
+}
+
+func TestErrorRoutes(t *testing.T) {
+	tbl := newTestTable(t, routeDesc())
+	for n, next := range []string{"B", "Rejected", "Coded", "Failed"} {
+		d := &door{}
+		d.entry = tbl.NewEntry(d)
+		state, _, err := d.entry.TransitWithData("T", code(n))
+		if state.Name != next {
+			t.Fatalf("error %d: state %s, expected %s", n, state.Name, next)
+		}
+		// the routed error is still returned
+		if (n > 0) != (err != nil) {
+			t.Fatalf("error %d: returned %v", n, err)
+		}
+	}
+	if a := tbl.Analyze(); !a.OK() {
+		t.Fatalf("analysis %+v, expected the routes reachable", a)
+	}
+}
+
+func TestInvalidErrorRoute(t *testing.T) {
+	for name, routes := range map[string][]ErrorRoute{
+		"not pointer": {{As: codeError{}, Next: "Failed"}},
+		"no target":   {{Next: "Failed"}},
+		"unknown":     {{Is: errBadRequest, Next: "Nowhere"}},
+	} {
+		desc := routeDesc()
+		desc.ErrMap = routes
+		_, err := NewTable(desc)
+		var ie *InvalidErrorRoute
+		if !errors.As(err, &ie) {
+			t.Fatalf("%s: error %v, expected *InvalidErrorRoute", name, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	fsm "github.com/HaesungSeo/goFSM/v2"
+)
+
+type Key struct {
+	id string
+}
+
+type Door struct {
+	name  string
+	entry *fsm.Entry[*Door, *Key]
+}
+
+func OpenDoor(door *Door, event fsm.Event, _ *Key) (fsm.HandleRetCode, error) {
+	fmt.Printf("Door %s: State=%s, Event=%s, Action=OpenDoor\n",
+		door.name, door.entry.State, event.Name)
+	return fsm.ExitOK, nil
+}
+
+func LockDoor(door *Door, event fsm.Event, key *Key) (fsm.HandleRetCode, error) {
+	if key == nil {
+		fmt.Printf("Door %s: State=%s, Event=%s, NOKEY Action=LockDoor\n",
+			door.name, door.entry.State, event.Name)
+		return fsm.ExitFail, nil
+	}
+	fmt.Printf("Door %s: State=%s, Event=%s, Key=%s, Action=LockDoor\n",
+		door.name, door.entry.State, event.Name, key.id)
+	return fsm.ExitOK, nil
+}
+
+func main() {
+	file := flag.String("f", "door.yaml", "table specification file")
+	user := flag.String("k", "", "key id")
+	flag.Parse()
+
+	// 1) register handlers by name
+	reg := fsm.HandlerRegistry[*Door, *Key]{
+		"OpenDoor": OpenDoor,
+		"LockDoor": LockDoor,
+	}
+
+	// 2) load FSM Instance from the specification file
+	fsmCtl, err := fsm.LoadTable(*file, reg)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return
+	}
+
+	door := Door{name: "myDoor"}
+	door.entry = fsmCtl.NewEntry(&door)
+
+	var key *Key = nil
+	if *user != "" {
+		key = &Key{id: *user}
+	}
+	for _, ev := range []string{"Open", "Lock"} {
+		state, _, err := door.entry.TransitWithData(ev, key)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			break
+		}
+		fmt.Printf("### %s Next State: %s\n", door.name, state.Name)
+	}
+	door.entry.PrintLog(0)
+}
@@ -1,9 +1,12 @@
 package fsm
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"runtime"
+	"strconv"
+	"sync"
 	"time"
 
 	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
@@ -38,9 +41,16 @@ type TrnasitLog struct {
 	ret    int       // Func's return code
 	next   string    // next event determined by Handler
 	err    error     // Error, from handle
+	exit   error     // Error, from OnExit of the current State
+	enter  error     // Error, from OnEnter of the next State
+
+	deferred bool // the event is deferred by the State
+	replayed bool // the event was deferred, replayed after the State changed
 }
 
 // FSM Entry
+// State, Logs and Datas are guarded by the Entry,
+// other goroutines MUST use CurrentState(), Get(), Set() and PrintLog() to access them
 type Entry[OWNER any, USERDATA any] struct {
 	Owner  OWNER                   // FSM owner
 	table  *Table[OWNER, USERDATA] // FSM Rule for this Entry
@@ -48,23 +58,51 @@ type Entry[OWNER any, USERDATA any] struct {
 	Logs   []*TrnasitLog           // transition log, for debug
 	LogMax int
 	Datas  map[string]interface{} // storage for temp datas
+
+	mu      sync.RWMutex     // guards State, Logs, Datas
+	active  []State          // active leaf States, one per region, guarded by mu
+	history map[string]State // last State of each State Group, guarded by mu
+
+	deferred []deferredEvent[USERDATA] // deferred events, guarded by mu
+	serial   *sync.Mutex               // serializes transitions, if not nil
+	queue    queue[USERDATA]           // posted events, see Run()
+
+	timer    Timer  // timeout of the current State
+	timerGen uint64 // generation of the timer, guarded by mu
+
+	id    string // Entry ID, for Store
+	store Store  // persistent store, if not nil
+
+	listeners []Listener[OWNER, USERDATA] // Listeners for this Entry
 }
 
 // Set stores tempral variables.
 // HandleFunc can call Set() to store temporal data needed between handleFuncs
 func (e *Entry[OWNER, USERDATA]) Set(key string, value interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.Datas[key] = value
 }
 
 // Get returns the stored tempral variables.
 // HandleFunc can call Get() to get temporal data which saved by other handleFuncs
 func (e *Entry[OWNER, USERDATA]) Get(key string) interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	if v, ok := e.Datas[key]; ok {
 		return v
 	}
 	return nil
 }
 
+// CurrentState returns the current State,
+// safe to call while other goroutine transits the Entry
+func (e *Entry[OWNER, USERDATA]) CurrentState() State {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.State
+}
+
 // status represent the end of transition
 type EndOfTrans bool
 
@@ -74,13 +112,37 @@ type EndOfTrans bool
 //	error - handler error, if any
 type HandleFuncv2[OWNER any, USERDATA any] func(Owner OWNER, event Event, UserData USERDATA) (HandleRetCode, error)
 
+// FSM State Event Guard Function
+// returns true if the handle accepts the event
+type GuardFunc[OWNER any, USERDATA any] func(Owner OWNER, event Event, UserData USERDATA) bool
+
 type CandMap map[HandleRetCode]string
 
+// FSM State Entry/Exit Action Function
+// state is the State entered or left, event is the Event causing the transition
+type StateFunc[OWNER any, USERDATA any] func(Owner OWNER, state State, event Event, UserData USERDATA) error
+
+// FSM State Entry/Exit Actions
+type StateAction[OWNER any, USERDATA any] struct {
+	OnEnter    StateFunc[OWNER, USERDATA]    // called when the State is entered
+	OnExit     StateFunc[OWNER, USERDATA]    // called when the State is left
+	OnEnterCtx StateFuncCtx[OWNER, USERDATA] // called instead of OnEnter, if not nil
+	OnExitCtx  StateFuncCtx[OWNER, USERDATA] // called instead of OnExit, if not nil
+	EnterName  string                        // OnEnter function name
+	ExitName   string                        // OnExit function name
+}
+
 // FSM State Event Handler information
 type Handle[OWNER any, USERDATA any] struct {
-	Name    string                        // handle name
-	Func    HandleFuncv2[OWNER, USERDATA] // handle function
-	CandMap CandMap                       // valid next state candidates
+	Name      string                         // handle name
+	Func      HandleFuncv2[OWNER, USERDATA]  // handle function
+	FuncCtx   HandleFuncCtx[OWNER, USERDATA] // context-aware handle function, called instead of Func if not nil
+	CandMap   CandMap                        // valid next state candidates
+	Guard     GuardFunc[OWNER, USERDATA]     // guard condition, nil if always accepts
+	GuardCtx  GuardFuncCtx[OWNER, USERDATA]  // context-aware guard condition, checked instead of Guard if not nil
+	GuardName string                         // guard function name
+	ErrMap    []ErrorRoute                   // next states for the errors, checked before CandMap
+	Alt       *Handle[OWNER, USERDATA]       // next guarded handle for the same {State, Event}
 }
 
 // FSM Table
@@ -98,6 +160,49 @@ type Table[OWNER any, USERDATA any] struct {
 
 	// Handles indexted by State,Event
 	Handles map[State]map[Event]*Handle[OWNER, USERDATA]
+
+	// Parent States indexed by child State
+	Parents map[State]State
+
+	// Initial child States indexed by composite State
+	Initials map[State]State
+
+	// Region States indexed by parallel State, in order of declaration
+	Regions map[State][]State
+
+	// State Groups indexed by name, for the history targets
+	Groups map[string]*StateGroup
+
+	// Deferred Events indexed by State
+	Defers map[State]map[Event]interface{}
+
+	// maximum number of deferred events per Entry
+	DeferMax int
+
+	// Entry/Exit Actions indexed by State
+	Actions map[State]*StateAction[OWNER, USERDATA]
+
+	// Timeouts indexed by State
+	Timeouts map[State]*StateTimeout
+
+	// Clock for log and timeout
+	Clock Clock
+
+	// Listeners for all Entries
+	Listeners []Listener[OWNER, USERDATA]
+
+	// Codec for Entry.Datas, used by Snapshot and Restore
+	DataCodec DataCodec
+
+	// what the Entry does when the handle panics, and the State to move to by PanicRoute
+	PanicPolicy PanicPolicy
+	PanicState  State
+
+	// next States for the errors of any handle, checked after Handle.ErrMap
+	ErrMap []ErrorRoute
+
+	// transitions taken by the Entries, nil if not enabled, see EnableCoverage()
+	cov *coverage[OWNER, USERDATA]
 }
 
 // FSM Event Action Description Table
@@ -144,25 +249,134 @@ type Table[OWNER any, USERDATA any] struct {
 // CandMap[100] stores the "DoNext" next state for the return code UserDefinedCode1(100)
 // CandMap[200] stores the "TryAgain" next state for the return code UserDefinedCode2(200)
 // CandMap[300] stores the "CheckRequest" next state for the return code UserDefinedCode3(300)
+//
+// Guard is optional, evaluated before Func, the event is handled only if the Guard accepts
+// several EventDesc of the same Event may be given to a State, distinguished by Guards,
+// they are checked in order of declaration, and only the last one may omit the Guard
+//
+//	{Event: "Open", Guard: HasPermission, Func: OpenDoor, CandList: []string{"Opened"}},
+//	{Event: "Open", Func: Deny, CandList: []string{"Closed"}},
+//
+// Event AnyEvent declares the default handle of the State, for any event the State does not handle
+// the event never declared by any EventDesc is still *InvalidEvent
+//
+// ErrMap is optional, routes the error returned by Func to the next State, regardless of the return code
+// the routes are checked in order, then TableDesc.ErrMap, and CandMap is used if none matches
+// the error is still returned by TransitWithData()
+//
+//	{Event: "Add", Func: DoAdd, CandList: []string{"Added"}, ErrMap: []fsm.ErrorRoute{
+//	    {Is: ErrBadRequest, Next: "Rejected"},
+//	}},
+//
+// FuncCtx and GuardCtx are the context-aware variants of Func and Guard, see TransitContext()
+// give either of Func or FuncCtx, and either of Guard or GuardCtx, not both
 type EventDesc[OWNER any, USERDATA any] struct {
-	Event    string                        // Event
-	Func     HandleFuncv2[OWNER, USERDATA] // Handler for this {State, Event}
-	CandMap  CandMap                       // valid next state candidates,
-	CandList []string                      // valid next state candidates,
+	Event    string                         // Event
+	Name     string                         // handle name, if empty, the name of Func
+	Guard    GuardFunc[OWNER, USERDATA]     // Guard for this {State, Event}, nil if always accepts
+	GuardCtx GuardFuncCtx[OWNER, USERDATA]  // context-aware Guard
+	Func     HandleFuncv2[OWNER, USERDATA]  // Handler for this {State, Event}
+	FuncCtx  HandleFuncCtx[OWNER, USERDATA] // context-aware Handler
+	ErrMap   []ErrorRoute                   // next states for the errors, in order, checked before CandMap
+	CandMap  CandMap                        // valid next state candidates,
+	CandList []string                       // valid next state candidates,
 	// if nil, handler MUST PROVIDE next state
 }
 
+// FSM State Description
+// OnEnter and OnExit are optional, called by TransitWithData() when the State changes,
+// in order of Func, OnExit of the current State, then OnEnter of the next State,
+// see Parent for the order with the ancestors
+// transition to the same State calls neither of them
+// OnEnterCtx and OnExitCtx are their context-aware variants, see TransitContext(),
+// give either of OnEnter or OnEnterCtx, and either of OnExit or OnExitCtx, not both
+//
+// Timeout and TimeoutEvent are optional, if the Entry stays in the State for Timeout,
+// TimeoutEvent is injected to the Entry, the State MUST handle TimeoutEvent
+// the timer is cancelled when the Entry leaves the State
+// the injected event transits the Entry from the goroutine of the Clock, or is posted if Run()
+// is processing the Entry, thus every Entry of the Table serializes transitions, see NewEntry()
+// the Result of the timeout is passed to the callback of Entry.OnResult()
+//
+// Parent is optional, makes the State a child of the Parent State,
+// the event not handled by the State is handled by the nearest ancestor which handles it
+// the Entry is always in a leaf State, the composite State given as the next State
+// or InitState is resolved to its Initial child, recursively
+// on transition, the States from the current State up to the common ancestor are left
+// from the innermost, then the States down to the next State are entered from the outermost
+//
+//	{State: "Connected", Initial: "Idle", Events: []fsm.EventDesc[*MyOwner, *MyData]{
+//	    {Event: "Abort", Func: DoAbort, CandList: []string{"Disconnected"}},
+//	}},
+//	{State: "Idle", Parent: "Connected", Events: ...},
+//	{State: "Busy", Parent: "Connected", Events: ...},
+//
+// the composite State can not have Timeout
+//
+// Parallel makes the children of the State its regions, active at the same time
+// entering the parallel State enters every region, the Entry has one active leaf State
+// per region, see Entry.ActiveStates(), and the event is handled by every region handling it
+// the target in a region MUST be given by the State in the same region,
+// other States target the parallel State itself
+// the States in the regions can not have Timeout
+//
+//	{State: "Device", Parallel: true},
+//	{State: "Power", Parent: "Device", Initial: "Off", ...},
+//	{State: "Link", Parent: "Device", Initial: "Down", ...},
+//
+// State AnyState declares the handles for any State, it can not have other fields than Events
+// the handle is looked up in order of precedence,
+// the State and its ancestors, their AnyEvent handles, the AnyState handle, then its AnyEvent handle
+//
+//	{State: fsm.AnyState, Events: []fsm.EventDesc[*MyOwner, *MyData]{
+//	    {Event: "Abort", Func: DoAbort, CandList: []string{"Aborted"}},
+//	}},
+//
+// Defer is optional, the events held by the Entry while it is in the State or its descendants,
+// unless the descendant handles the event itself
+// held events are replayed in order whenever the State changes, see TableDesc.DeferMax
+//
+//	{State: "Opening", Defer: []string{"Lock"}, Events: ...},
 type StateDesc[OWNER any, USERDATA any] struct {
-	State  string
-	Events []EventDesc[OWNER, USERDATA]
+	State        string
+	Parent       string // parent State, empty if top level
+	Initial      string // initial child State, if the State is composite
+	Parallel     bool   // children are the regions, if the State is composite
+	Events       []EventDesc[OWNER, USERDATA]
+	OnEnter      StateFunc[OWNER, USERDATA]    // Entry Action
+	OnExit       StateFunc[OWNER, USERDATA]    // Exit Action
+	OnEnterCtx   StateFuncCtx[OWNER, USERDATA] // context-aware Entry Action
+	OnExitCtx    StateFuncCtx[OWNER, USERDATA] // context-aware Exit Action
+	Timeout      time.Duration                 // maximum duration to stay in the State
+	TimeoutEvent string                        // Event injected on Timeout
+	Defer        []string                      // Events deferred in the State
 }
 
 // FSM State-Event Table Descriptor
 type TableDesc[OWNER any, USERDATA any] struct {
-	InitState   string   // Initial State for Entry
-	FinalStates []string // Final States for Entry
-	LogMax      int      // maximum lengh of log
+	InitState   string    // Initial State for Entry
+	FinalStates []string  // Final States for Entry
+	LogMax      int       // maximum lengh of log
+	Strict      bool      // NewTable fails if Analyze() reports any finding
+	Clock       Clock     // Clock for log and timeout, SystemClock if nil
+	DataCodec   DataCodec // Codec for Entry.Datas of Snapshot, JSONDataCodec if nil
 	States      []StateDesc[OWNER, USERDATA]
+	Groups      []GroupDesc  // State Groups for the history targets, see History()
+	DeferMax    int          // maximum number of deferred events per Entry, DefaultDeferMax if 0
+	PanicPolicy PanicPolicy  // what the Entry does when the handle panics, PanicStay if empty
+	PanicState  string       // State to move to when the handle panics, for PanicRoute
+	ErrMap      []ErrorRoute // next States for the errors of any handle, see EventDesc.ErrMap
+}
+
+// handleName returns the name of the handle described by event
+func handleName[OWNER any, USERDATA any](event *EventDesc[OWNER, USERDATA]) string {
+	if event.Name != "" {
+		return event.Name
+	}
+	if event.FuncCtx != nil {
+		return getFunctionName(event.FuncCtx)
+	}
+	return getFunctionName(event.Func)
 }
 
 func getFunctionName(i interface{}) string {
@@ -198,11 +412,11 @@ type HandleRetCodeRangeError struct {
 }
 
 func (e *HandleRetCodeRangeError) Error() string {
-	return e.Err.Error() + ": Code=" + string(e.Code) + ", State=" + e.State +
+	return e.Err.Error() + ": Code=" + strconv.Itoa(int(e.Code)) + ", State=" + e.State +
 		", Event=" + e.Event + ", Func=" + e.Handle
 }
 
-func (e *HandleRetCodeRangeError) Unwrap() error { return nil }
+func (e *HandleRetCodeRangeError) Unwrap() error { return e.Err }
 
 type HandleRetCodeDupError struct {
 	State  string // current state
@@ -213,11 +427,11 @@ type HandleRetCodeDupError struct {
 }
 
 func (e *HandleRetCodeDupError) Error() string {
-	return e.Err.Error() + ": Code=" + string(e.Code) + ", State=" + e.State +
+	return e.Err.Error() + ": Code=" + strconv.Itoa(int(e.Code)) + ", State=" + e.State +
 		", Event=" + e.Event + ", Func=" + e.Handle
 }
 
-func (e *HandleRetCodeDupError) Unwrap() error { return nil }
+func (e *HandleRetCodeDupError) Unwrap() error { return e.Err }
 
 type HandleEmptyRetCodeError struct {
 	State  string // current state
@@ -231,7 +445,7 @@ func (e *HandleEmptyRetCodeError) Error() string {
 		", Event=" + e.Event + ", Func=" + e.Handle
 }
 
-func (e *HandleEmptyRetCodeError) Unwrap() error { return nil }
+func (e *HandleEmptyRetCodeError) Unwrap() error { return e.Err }
 
 type Opts map[string]map[int]interface{}
 
@@ -243,6 +457,13 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 	tbl.States = make(map[State]interface{})
 	tbl.Events = make(map[Event]interface{})
 	tbl.Handles = make(map[State]map[Event]*Handle[OWNER, USERDATA])
+	tbl.Actions = make(map[State]*StateAction[OWNER, USERDATA])
+	tbl.Timeouts = make(map[State]*StateTimeout)
+	tbl.Parents = make(map[State]State)
+	tbl.Initials = make(map[State]State)
+	tbl.Regions = make(map[State][]State)
+	tbl.Groups = make(map[string]*StateGroup)
+	tbl.Defers = make(map[State]map[Event]interface{})
 	tbl.FSMap = make(map[string]interface{})
 
 	tbl.InitState = State{d.InitState}
@@ -251,40 +472,140 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 		tbl.FSMap[s] = nil
 	}
 	tbl.LogMax = d.LogMax
+	tbl.DeferMax = d.DeferMax
+	tbl.Clock = d.Clock
+	if tbl.Clock == nil {
+		tbl.Clock = SystemClock
+	}
+	tbl.DataCodec = d.DataCodec
+	tbl.PanicPolicy = d.PanicPolicy
+	if tbl.PanicPolicy == "" {
+		tbl.PanicPolicy = PanicStay
+	}
+	tbl.PanicState = State{d.PanicState}
+
+	if err := checkWildcards(d); err != nil {
+		return nil, err
+	}
+	if err := checkFuncs(d); err != nil {
+		return nil, err
+	}
 
 	// Initialize given states, events
 	for _, state := range d.States {
-		// Index State
-		tbl.States[State{state.State}] = nil
+		// Index State, except the wildcard
+		if state.State != AnyState {
+			tbl.States[State{state.State}] = nil
+		}
 
-		for _, event := range state.Events {
-			// Index Events
-			tbl.Events[Event{event.Event}] = nil
+		if state.Timeout > 0 {
+			// Index Timeout Event
+			tbl.Events[Event{state.TimeoutEvent}] = nil
+		}
 
-			// Index NextState
-			for _, nstate := range event.CandList {
-				tbl.States[State{nstate}] = nil
+		// Index Deferred Events
+		for _, ev := range state.Defer {
+			tbl.Events[Event{ev}] = nil
+			if _, ok := tbl.Defers[State{state.State}]; !ok {
+				tbl.Defers[State{state.State}] = make(map[Event]interface{})
 			}
-			// Index NextState
-			for _, v := range event.CandMap {
-				tbl.States[State{v}] = nil
+			tbl.Defers[State{state.State}][Event{ev}] = nil
+		}
+
+		for _, event := range state.Events {
+			// Index Events, except the wildcard
+			if event.Event != AnyEvent {
+				tbl.Events[Event{event.Event}] = nil
+			}
+
+			// Index NextState, except the history target
+			for _, nstate := range event.nexts() {
+				if _, ok := historyGroup(nstate); !ok {
+					tbl.States[State{nstate}] = nil
+				}
 			}
 		}
 	}
 
+	// Index State Hierarchy
+	if err := tbl.buildHierarchy(d); err != nil {
+		return nil, err
+	}
+
+	// Index State Groups
+	if err := tbl.buildGroups(d); err != nil {
+		return nil, err
+	}
+
+	// check the State to move to when the handle panics
+	if err := tbl.checkPanicPolicy(d); err != nil {
+		return nil, err
+	}
+
+	// check the States to move to when the handle returns the error
+	if err := tbl.checkErrorRoutes(d); err != nil {
+		return nil, err
+	}
+	tbl.ErrMap = d.ErrMap
+
 	// Allocate Handles
 	for _, state := range d.States {
 		tbl.Handles[State{state.State}] = make(map[Event]*Handle[OWNER, USERDATA])
 	}
 
+	// Add User defined Entry/Exit Actions
+	for _, state := range d.States {
+		if state.OnEnter == nil && state.OnExit == nil && state.OnEnterCtx == nil && state.OnExitCtx == nil {
+			continue
+		}
+		action, ok := tbl.Actions[State{state.State}]
+		if !ok {
+			action = &StateAction[OWNER, USERDATA]{}
+			tbl.Actions[State{state.State}] = action
+		}
+		if state.OnEnter != nil {
+			action.OnEnter = state.OnEnter
+			action.EnterName = getFunctionName(state.OnEnter)
+		}
+		if state.OnExit != nil {
+			action.OnExit = state.OnExit
+			action.ExitName = getFunctionName(state.OnExit)
+		}
+		if state.OnEnterCtx != nil {
+			action.OnEnterCtx = state.OnEnterCtx
+			action.EnterName = getFunctionName(state.OnEnterCtx)
+		}
+		if state.OnExitCtx != nil {
+			action.OnExitCtx = state.OnExitCtx
+			action.ExitName = getFunctionName(state.OnExitCtx)
+		}
+	}
+
 	// Add User defined State-Event-Handles
 	for _, state := range d.States {
 		for _, event := range state.Events {
-			hName := getFunctionName(event.Func)
+			if event.Func == nil && event.FuncCtx == nil {
+				return nil, &UndefinedHandle{
+					State: state.State,
+					Event: event.Event,
+					Err:   fsmerror.ErrHandleNotExists,
+				}
+			}
+			hName := handleName(&event)
 			handle := &Handle[OWNER, USERDATA]{
-				hName,
-				event.Func,
-				make(CandMap, 0),
+				Name:     hName,
+				Func:     event.Func,
+				FuncCtx:  event.FuncCtx,
+				CandMap:  make(CandMap, 0),
+				Guard:    event.Guard,
+				GuardCtx: event.GuardCtx,
+				ErrMap:   event.ErrMap,
+			}
+			if event.Guard != nil {
+				handle.GuardName = getFunctionName(event.Guard)
+			}
+			if event.GuardCtx != nil {
+				handle.GuardName = getFunctionName(event.GuardCtx)
 			}
 			// build vaild next states for corresponding return codes
 			for idx, nstate := range event.CandList {
@@ -300,6 +621,7 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 							Event:  event.Event,
 							Handle: hName,
 							Code:   HandleRetCode(idx),
+							Err:    fsmerror.ErrDupRetCode,
 						}
 					}
 					handle.CandMap[HandleRetCode(idx)] = nstate
@@ -309,6 +631,7 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 						Event:  event.Event,
 						Handle: hName,
 						Code:   HandleRetCode(idx),
+						Err:    fsmerror.ErrInvalidRetCode,
 					}
 				}
 			}
@@ -319,6 +642,7 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 						Event:  event.Event,
 						Handle: hName,
 						Code:   HandleRetCode(idx),
+						Err:    fsmerror.ErrDupRetCode,
 					}
 				}
 				handle.CandMap[HandleRetCode(idx)] = nstate
@@ -337,8 +661,12 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 			if statefound {
 				old, handlefound := s[Event{event.Event}]
 				if handlefound {
-					if &old.Func != &handle.Func {
-						// state-event table MUST HAVE only one handle per entry
+					for old.Alt != nil {
+						old = old.Alt
+					}
+					if !old.guarded() {
+						// state-event table MUST HAVE only one handle per entry,
+						// unless distinguished by guards
 						return nil, &StateEventConflictError{
 							State:     state.State,
 							Event:     event.Event,
@@ -347,6 +675,9 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 							Err:       fsmerror.ErrDupHandle,
 						}
 					}
+					// Add guarded handle
+					old.Alt = handle
+					continue
 				}
 			}
 
@@ -359,7 +690,7 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 	for state, _ := range tbl.States {
 		// check the state has any handle
 		if hmap, ok := tbl.Handles[state]; !ok {
-			if _, ok := tbl.FSMap[state.Name]; !ok {
+			if _, ok := tbl.FSMap[state.Name]; !ok && len(tbl.Handles[State{AnyState}]) == 0 {
 				return &tbl, &UndefinedHandle{
 					State: state.Name,
 					Event: "any",
@@ -370,8 +701,12 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 			// check the state is final state and has a (useless) event handler
 			_, finalState := tbl.FSMap[state.Name]
 
-			// check the handle has any Funcion
-			if len(hmap) == 0 && !finalState {
+			// check the handle has any Funcion, including inherited ones
+			inherited := tbl.composite(state) || len(tbl.Handles[State{AnyState}]) > 0
+			for _, s := range tbl.path(state) {
+				inherited = inherited || len(tbl.Handles[s]) > 0
+			}
+			if !inherited && !finalState {
 				return &tbl, &UndefinedHandle{
 					State: state.Name,
 					Event: "any",
@@ -391,28 +726,36 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 		}
 	}
 
+	// check the state handles its timeout event
+	for _, state := range d.States {
+		if state.Timeout <= 0 {
+			continue
+		}
+		if !tbl.hasHandle(State{state.State}, Event{state.TimeoutEvent}) {
+			return &tbl, &UndefinedHandle{
+				State: state.State,
+				Event: state.TimeoutEvent,
+				Err:   fsmerror.ErrHandleNotExists,
+			}
+		}
+		tbl.Timeouts[State{state.State}] = &StateTimeout{
+			After: state.Timeout,
+			Event: Event{state.TimeoutEvent},
+		}
+	}
+
 	// check the next state-event has handler
 	for _, state := range d.States {
 		for _, event := range state.Events {
-			for _, nstate := range event.CandList {
+			for _, nstate := range event.nexts() {
 				// check the next state-event has handler
-				//tbl.States[State{nstate}] = nil
-				if _, ok := tbl.Handles[State{nstate}][Event{event.Event}]; !ok {
-					if _, ok := tbl.FSMap[nstate]; !ok {
-						return &tbl, &UndefinedHandle{
-							State: nstate,
-							Event: event.Event,
-							Err:   fsmerror.ErrHandleNotExists,
-						}
-					}
-				}
-			}
-			for _, nstate := range event.CandMap {
-				//tbl.States[State{v}] = nil
-				if _, ok := tbl.Handles[State{nstate}][Event{event.Event}]; !ok {
-					if _, ok := tbl.FSMap[nstate]; !ok {
+				// the history target is checked by the default of the group,
+				// AnyEvent is not checked
+				next := tbl.targets(nstate)[0]
+				if event.Event != AnyEvent && !tbl.handledOnEntry(next, Event{event.Event}) {
+					if _, ok := tbl.FSMap[next.Name]; !ok {
 						return &tbl, &UndefinedHandle{
-							State: nstate,
+							State: next.Name,
 							Event: event.Event,
 							Err:   fsmerror.ErrHandleNotExists,
 						}
@@ -421,7 +764,7 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 			}
 
 			// check all possible return code
-			hName := getFunctionName(event.Func)
+			hName := handleName(&event)
 			for _, fmap := range opts {
 				if retmap, ok := fmap[hName]; ok {
 					// we have validator
@@ -451,6 +794,13 @@ func NewTable[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], opts ...Op
 		}
 	}
 
+	// check reachability
+	if d.Strict {
+		if err := tbl.check(); err != nil {
+			return &tbl, err
+		}
+	}
+
 	return &tbl, nil
 }
 
@@ -465,7 +815,11 @@ func (tbl *Table[ONWER, USERDATA]) Dump() {
 
 	fmt.Printf("All States\n")
 	for state, _ := range tbl.States {
-		fmt.Printf("  [%s]\n", state)
+		if parent, ok := tbl.Parents[state]; ok {
+			fmt.Printf("  [%s] Parent[%s]\n", state, parent)
+		} else {
+			fmt.Printf("  [%s]\n", state)
+		}
 	}
 
 	fmt.Printf("All Events\n")
@@ -476,38 +830,78 @@ func (tbl *Table[ONWER, USERDATA]) Dump() {
 	for state, events := range tbl.Handles {
 		fmt.Printf("State[%s]\n", state)
 		for event, handle := range events {
-			keys := make([]HandleRetCode, 0, len(handle.CandMap))
-			for hrc := range handle.CandMap {
-				keys = append(keys, hrc)
-			}
-			for i, k := range keys {
-				switch i {
-				case 0:
-					// first return code
-					fmt.Printf("  Event[%s] Func[%s] Return code[%d] Next State[%s]\n",
-						event, handle.Name, k, handle.CandMap[HandleRetCode(k)])
-				default:
-					fmt.Printf("    Return code[%d] Next State[%s]\n", k, handle.CandMap[HandleRetCode(k)])
+			for ; handle != nil; handle = handle.Alt {
+				keys := make([]HandleRetCode, 0, len(handle.CandMap))
+				for hrc := range handle.CandMap {
+					keys = append(keys, hrc)
+				}
+				guard := ""
+				if handle.guarded() {
+					guard = " Guard[" + handle.GuardName + "]"
+				}
+				for i, k := range keys {
+					switch i {
+					case 0:
+						// first return code
+						fmt.Printf("  Event[%s]%s Func[%s] Return code[%d] Next State[%s]\n",
+							event, guard, handle.Name, k, handle.CandMap[HandleRetCode(k)])
+					default:
+						fmt.Printf("    Return code[%d] Next State[%s]\n", k, handle.CandMap[HandleRetCode(k)])
+					}
 				}
 			}
 		}
 	}
 }
 
+// Clone returns the copy of the Table, sharing the States, Events, Handles and the other maps
+// the coverage is not shared, the copy counts its transitions after its own EnableCoverage()
+func (tbl *Table[OWNER, USERDATA]) Clone() *Table[OWNER, USERDATA] {
+	clone := *tbl
+	clone.cov = nil
+	return &clone
+}
+
 // Create New FSM Entry Instance, controlled by Table(FSM Control) Instance
+// if any State has Timeout, the Entry serializes transitions as NewSyncEntry() does,
+// the timeout transits the Entry from the goroutine of the Clock
 // owner Entry Owner
 func (tbl *Table[OWNER, USERDATA]) NewEntry(owner OWNER) *Entry[OWNER, USERDATA] {
+	return tbl.newEntry(owner, nil)
+}
+
+func (tbl *Table[OWNER, USERDATA]) newEntry(owner OWNER, serial *sync.Mutex) *Entry[OWNER, USERDATA] {
 	entry := &Entry[OWNER, USERDATA]{}
+	entry.serial = serial
+	if serial == nil && len(tbl.Timeouts) > 0 {
+		entry.serial = &sync.Mutex{}
+	}
 	entry.Owner = owner
 	entry.table = tbl
-	entry.State = tbl.InitState
+	entry.active = tbl.leaves(tbl.InitState)
+	entry.State = tbl.container(entry.active)
+	entry.history = make(map[string]State)
 	entry.Logs = make([]*TrnasitLog, 0)
 	entry.LogMax = tbl.LogMax
 	entry.Datas = make(map[string]interface{})
+	entry.queue.wake = make(chan struct{}, 1)
+
+	entry.mu.Lock()
+	entry.remember()
+	entry.armTimer(entry.State)
+	entry.mu.Unlock()
 
 	return entry
 }
 
+// Create New FSM Entry Instance, which serializes transitions
+// TransitWithData() called by several goroutines runs one at a time,
+// thus HandleFunc MUST NOT call Transit() of the same Entry, it blocks forever
+// owner Entry Owner
+func (tbl *Table[OWNER, USERDATA]) NewSyncEntry(owner OWNER) *Entry[OWNER, USERDATA] {
+	return tbl.newEntry(owner, &sync.Mutex{})
+}
+
 // Invalid Event Error
 type InvalidEvent struct {
 	Event string
@@ -560,75 +954,385 @@ type UndefinedRetCode struct {
 }
 
 func (e *UndefinedRetCode) Error() string {
-	return e.Err.Error() + ": Code=" + string(e.RetCode) +
+	return e.Err.Error() + ": Code=" + strconv.Itoa(int(e.RetCode)) +
 		", State=" + e.State + ", Event=" + e.Event +
 		", Handle=" + e.Handle
 }
 
 func (e *UndefinedRetCode) Unwrap() error { return e.Err }
 
+// Guard Rejected Error, every guard of the {State, Event} rejected the event
+type GuardRejected struct {
+	State string
+	Event string
+	Err   error
+}
+
+func (e *GuardRejected) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event
+}
+
+func (e *GuardRejected) Unwrap() error { return e.Err }
+
+// Entry/Exit Action Error
+type StateActionError struct {
+	State  string // State entered or left
+	Event  string // Event causing the transition
+	Action string // "OnEnter" or "OnExit"
+	Func   string // action function
+	Err    error  // Error, from action
+}
+
+func (e *StateActionError) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event +
+		", Action=" + e.Action + ", Func=" + e.Func
+}
+
+func (e *StateActionError) Unwrap() error { return e.Err }
+
 // Do FSM
 // ev Event
 // userData event specific data
+// in parallel States, the event is handled by every region which handles it,
+// in order of declaration of the regions
 // returns
 //
-//	State - next state
-//	bool - represents end of transition
-//	error - handler returned error
+//	State - next state, the parallel State if the Entry is in its regions, see ActiveStates()
+//	bool - represents end of transition, all regions are in the final States
+//	error - handler returned error,
+//	        or *StateActionError if handler succeeded but OnExit or OnEnter failed,
+//	        or *PersistError if the Store failed, the transition is rolled back,
+//	        or *GuardRejected if no guard accepted the event, the State is not changed
+//	        or *EventDeferred if the State defers the event, the State is not changed
+//	        or *DeferOverflow if the State defers the event but the Entry holds too many events
+//	        or *TransitCanceled if ctx of TransitContext() is done, the State is not changed
+//	        or *HandlerPanic if the handle panicked, the State is changed by TableDesc.PanicPolicy
+//
+// the deferred events are replayed in order after the State changed, before TransitWithData() returns,
+// then State and bool are of the Entry after the replay, error is of the event, see Logs for the replayed ones
 func (e *Entry[OWNER, USERDATA]) TransitWithData(ev string, userData USERDATA) (State, bool, error) {
+	return e.transit(context.Background(), ev, userData, 0)
+}
+
+// transit does FSM, gen is the timer generation if the event is injected by the timeout
+func (e *Entry[OWNER, USERDATA]) transit(ctx context.Context, ev string, userData USERDATA, gen uint64) (State, bool, error) {
+	if e.serial != nil {
+		e.serial.Lock()
+		defer e.serial.Unlock()
+	}
+
+	if gen != 0 && e.staleTimer(gen) {
+		// the Entry left the State already
+		return e.CurrentState(), false, errStaleTimeout
+	}
+
+	next, eot, err := e.process(ctx, ev, userData, false)
+	if e.Deferred() > 0 && e.replay() {
+		// the replayed events moved the Entry further
+		e.mu.RLock()
+		next, eot = e.State, e.table.final(e.active)
+		e.mu.RUnlock()
+	}
+	return next, eot, err
+}
+
+// process handles the event, replayed is set if the event was deferred
+func (e *Entry[OWNER, USERDATA]) process(ctx context.Context, ev string, userData USERDATA, replayed bool) (State, bool, error) {
+	e.mu.RLock()
+	state := e.State.Name
+	active := e.active
+	store, id := e.store, e.id
+	var saved *entryState[USERDATA]
+	if store != nil {
+		saved = e.keep()
+	}
+	n := e.newNotifier(ctx, state, ev, userData)
+	e.mu.RUnlock()
+
 	event := Event{ev}
 	_, found := e.table.Events[event]
 	if !found {
-		return State{}, true, &InvalidEvent{Event: ev, Err: fsmerror.ErrInvalidEvent}
+		err := &InvalidEvent{Event: ev, Err: fsmerror.ErrInvalidEvent}
+		if n != nil {
+			n.info.Err = err
+			n.notify(Listener[OWNER, USERDATA].InvalidEvent)
+		}
+		return State{}, true, err
 	}
 
-	handle, found := e.table.Handles[e.State][event]
+	if err := canceled(ctx, state, ev); err != nil {
+		// abort before any guard runs, stay in the current state
+		if n != nil {
+			n.info.Err = err
+			n.notify(Listener[OWNER, USERDATA].AfterTransit)
+		}
+		return State{state}, false, err
+	}
+
+	if e.table.deferredIn(active, event) {
+		// hold the event until the state changes
+		return e.deferEvent(ctx, state, ev, userData, n)
+	}
+
+	// the first handle accepted by its guard, of the active states or their ancestors
+	steps, found := e.table.dispatch(ctx, active, event, e.Owner, userData)
 	if !found {
 		// no handle for this state-event pair
 		// may stop the transition for this {state, event} pair
-		return State{}, true, &UndefinedHandle{State: e.State.Name, Event: ev, Err: fsmerror.ErrHandleNotExists}
+		err := &UndefinedHandle{State: state, Event: ev, Err: fsmerror.ErrHandleNotExists}
+		if n != nil {
+			n.info.Err = err
+			n.notify(Listener[OWNER, USERDATA].InvalidEvent)
+		}
+		return State{}, true, err
+	}
+	if len(steps) == 0 {
+		// skip the transition, stay in the current state
+		err := &GuardRejected{State: state, Event: ev, Err: fsmerror.ErrGuardRejected}
+		if n != nil {
+			n.info.Err = err
+			n.notify(Listener[OWNER, USERDATA].AfterTransit)
+		}
+		return State{state}, false, err
 	}
 
 	eot := false // remark the end of transit
-	state := e.State.Name
-	retCode, err := handle.Func(e.Owner, event, userData)
+	var err error
+	committed := false
+	logs := make([]*TrnasitLog, 0, len(steps))
+	notifiers := make([]*notifier[OWNER, USERDATA], 0, len(steps))
+	for _, step := range steps {
+		e.mu.RLock()
+		active = e.active
+		e.mu.RUnlock()
+		if !containsState(active, step.from) {
+			// left by the previous step
+			continue
+		}
+		if cerr := canceled(ctx, step.from.Name, ev); cerr != nil {
+			// the remaining regions are not handled
+			if err == nil {
+				err = cerr
+			}
+			break
+		}
+
+		sn := n.fork(step.from.Name)
+		log, ok, stop := e.fire(ctx, active, step, event, userData, replayed, sn)
+		logs = append(logs, log)
+		notifiers = append(notifiers, sn)
+		if stop {
+			eot = true
+		}
+		committed = committed || ok
+		if err == nil {
+			err = log.err
+		}
+	}
 
-	if state, ok := handle.CandMap[retCode]; !ok {
+	e.mu.Lock()
+	if store != nil && committed {
+		snap, serr := e.snapshot()
+		if serr == nil {
+			serr = store.Save(id, snap)
+		}
+		if serr != nil {
+			// roll back, log the failure instead
+			e.rollback(saved)
+			eot = true
+			err = &PersistError{
+				ID:    id,
+				State: saved.state.Name,
+				Event: ev,
+				Cause: serr,
+				Err:   fsmerror.ErrPersist,
+			}
+			for i, log := range logs {
+				log.next = saved.state.Name
+				log.err = err
+				e.appendLog(log)
+				if sn := notifiers[i]; sn != nil {
+					sn.info.Next = log.next
+					sn.info.Err = err
+				}
+			}
+		}
+	}
+	next := e.State
+	if e.table.final(e.active) {
+		// every active state is defined as final state
 		eot = true
+	}
+	e.mu.Unlock()
+
+	for _, sn := range notifiers {
+		sn.notify(Listener[OWNER, USERDATA].AfterTransit)
+	}
+
+	return next, eot, err
+}
+
+// fire runs the handle for the active leaf State, then moves the Entry to the next States
+// returns the log of the step, reports the State changed by the step,
+// and stop is set if the return code is undefined
+func (e *Entry[OWNER, USERDATA]) fire(ctx context.Context, active []State, st step[OWNER, USERDATA], event Event, userData USERDATA, replayed bool, n *notifier[OWNER, USERDATA]) (log *TrnasitLog, ok bool, stop bool) {
+	handle := st.handle
+	state := st.from.Name
+
+	if n != nil {
+		n.info.Handle = handle.Name
+		n.notify(Listener[OWNER, USERDATA].BeforeTransit)
+	}
+
+	// the handle runs unlocked, it may call Set() and Get()
+	// the panic of the handle is recovered, and handled by the PanicPolicy
+	retCode, hp, err := e.protect(ctx, handle, state, event, userData)
+
+	if n != nil {
+		n.info.RetCode = retCode
+		if err != nil {
+			n.info.Err = err
+			n.notify(Listener[OWNER, USERDATA].HandlerError)
+		}
+	}
+
+	log = &TrnasitLog{}
+	log.time = e.table.Clock.Now()
+	log.state = state
+	log.event = event.Name
+	log.handle = handle.Name
+	log.ret = int(retCode)
+	log.replayed = replayed
+
+	target, defined := handle.CandMap[retCode]
+	routed := false // the next state is not of CandMap
+	if err != nil && hp == nil {
+		if nstate, ok := e.table.route(handle, err); ok {
+			target, defined, routed = nstate, true, true
+		}
+	}
+	if hp != nil {
+		target, defined, routed = e.table.PanicState.Name, e.table.PanicPolicy == PanicRoute, true
+	}
+	next := state
+	cerr := canceled(ctx, state, event.Name)
+	switch {
+	case hp != nil && !defined:
+		// stay in the current state, err is the panic
+	case cerr != nil:
+		// the handle ran, but the State is not changed
+		err = cerr
+	case !defined:
+		stop = true
 		err = &UndefinedRetCode{
-			State:   e.State.Name,
-			Event:   ev,
+			State:   state,
+			Event:   event.Name,
 			Handle:  handle.Name,
 			RetCode: retCode,
 			Err:     fsmerror.ErrInvalidRetCode,
 		}
-	} else {
-		e.State = State{state}
+		if n != nil {
+			n.info.Err = err
+			n.notify(Listener[OWNER, USERDATA].UndefinedRetCode)
+		}
+	default:
+		ok = true
+		if !routed {
+			e.table.cover(handle, retCode)
+		}
+		// the history target is resolved to the last state of the group,
+		// the composite state is entered through its initial child, or all its regions
+		e.mu.RLock()
+		to := e.resolve(target)
+		e.mu.RUnlock()
+		next = e.table.container(e.table.leaves(to)).Name
+		exits, enters, leaves := e.table.transition(active, st.from, to)
+
+		// leave the current state, and its ancestors up to the common one
+		for _, s := range exits {
+			action := e.table.Actions[s]
+			if xerr := action.exit(ctx, e.Owner, s, event, userData); xerr != nil && log.exit == nil {
+				log.exit = &StateActionError{
+					State:  s.Name,
+					Event:  event.Name,
+					Action: "OnExit",
+					Func:   action.ExitName,
+					Err:    xerr,
+				}
+			}
+		}
 
-		// check the next state is defined as final state
-		if _, ok := e.table.FSMap[e.State.Name]; ok {
-			eot = true
+		if len(exits) > 0 || len(enters) > 0 {
+			e.mu.Lock()
+			e.active = leaves
+			e.remember()
+			if current := e.table.container(leaves); current != e.State {
+				e.State = current
+				e.armTimer(e.State)
+			}
+			e.mu.Unlock()
+		}
+
+		// enter the ancestors of the next state, then the next state
+		for _, s := range enters {
+			action := e.table.Actions[s]
+			if xerr := action.enter(ctx, e.Owner, s, event, userData); xerr != nil && log.enter == nil {
+				log.enter = &StateActionError{
+					State:  s.Name,
+					Event:  event.Name,
+					Action: "OnEnter",
+					Func:   action.EnterName,
+					Err:    xerr,
+				}
+			}
+		}
+	}
+
+	if err == nil {
+		if log.exit != nil {
+			err = log.exit
+		} else if log.enter != nil {
+			err = log.enter
 		}
 	}
+	log.next = next
+	log.err = err
 
-	if e.LogMax > 0 {
-		log := &TrnasitLog{}
-		log.time = time.Now()
-		log.state = state
-		log.event = event.Name
-		log.handle = handle.Name
-		log.ret = int(retCode)
-		log.next = e.State.Name
-		log.err = err
+	e.mu.Lock()
+	e.appendLog(log)
+	e.mu.Unlock()
 
-		if len(e.Logs) >= e.LogMax {
-			// truncate old
-			e.Logs = e.Logs[1:len(e.Logs)]
+	if n != nil {
+		n.info.Next = next
+		n.info.Err = err
+	}
+	if hp != nil && e.table.PanicPolicy == PanicRepanic {
+		panic(hp.Value)
+	}
+	return log, ok, stop
+}
+
+// containsState reports the state is one of states
+func containsState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
 		}
-		e.Logs = append(e.Logs, log)
 	}
+	return false
+}
 
-	return e.State, eot, err
+// appendLog appends the log, truncates old logs if exceeds LogMax
+// e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) appendLog(log *TrnasitLog) {
+	if e.LogMax <= 0 {
+		return
+	}
+	if len(e.Logs) >= e.LogMax {
+		// truncate old
+		e.Logs = e.Logs[1:len(e.Logs)]
+	}
+	e.Logs = append(e.Logs, log)
 }
 
 // Do FSM
@@ -647,6 +1351,9 @@ func t2s(t time.Time) string {
 //
 //	otherwise print all logs
 func (e *Entry[OWNER, USERDATA]) PrintLog(last int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	nLogs := len(e.Logs)
 	start := 0
 	if last > 0 && nLogs > last {
@@ -656,11 +1363,24 @@ func (e *Entry[OWNER, USERDATA]) PrintLog(last int) {
 	for i := start; i < nLogs; i++ {
 		log := e.Logs[i]
 		if log.err != nil {
-			fmt.Printf("%s State=[%s] Event=[%s] Func=[%s] RetCode=[%d] NextState=[%s] Err=[%s]\n",
+			fmt.Printf("%s State=[%s] Event=[%s] Func=[%s] RetCode=[%d] NextState=[%s] Err=[%s]",
 				t2s(log.time), log.state, log.event, log.handle, log.ret, log.next, log.err.Error())
 		} else {
-			fmt.Printf("%s State=[%s] Event=[%s] Func=[%s] RetCode=[%d] NextState=[%s]\n",
+			fmt.Printf("%s State=[%s] Event=[%s] Func=[%s] RetCode=[%d] NextState=[%s]",
 				t2s(log.time), log.state, log.event, log.handle, log.ret, log.next)
 		}
+		if log.exit != nil && log.exit != log.err {
+			fmt.Printf(" ExitErr=[%s]", log.exit.Error())
+		}
+		if log.enter != nil && log.enter != log.err {
+			fmt.Printf(" EnterErr=[%s]", log.enter.Error())
+		}
+		if log.deferred {
+			fmt.Printf(" Deferred")
+		}
+		if log.replayed {
+			fmt.Printf(" Replayed")
+		}
+		fmt.Printf("\n")
 	}
 }
@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"testing"
+)
+
+// door is the owner of the test Entries
+type door struct {
+	entry *Entry[*door, *int]
+}
+
+// code returns the event data of the return code
+func code(n int) *int {
+	return &n
+}
+
+// newTestTable creates the Table, or fails the test
+func newTestTable(t *testing.T, d *TableDesc[*door, *int]) *Table[*door, *int] {
+	t.Helper()
+	tbl, err := NewTable(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+// expectState fails the test if the Entry is not in the State
+func expectState(t *testing.T, entry *Entry[*door, *int], state string) {
+	t.Helper()
+	if s := entry.CurrentState(); s.Name != state {
+		t.Fatalf("state %s, expected %s", s.Name, state)
+	}
+}
+
+// retCode returns the return code given as the event data, ExitOK if nil
+func retCode(d *door, ev Event, n *int) (HandleRetCode, error) {
+	if n == nil {
+		return ExitOK, nil
+	}
+	return HandleRetCode(*n), nil
+}
+
+// doorDesc returns the door of Closed, Opened and the final State Locked,
+// the event data is the return code, 0 for the first candidate
+func doorDesc() *TableDesc[*door, *int] {
+	return &TableDesc[*door, *int]{
+		InitState:   "Closed",
+		FinalStates: []string{"Locked"},
+		LogMax:      10,
+		States: []StateDesc[*door, *int]{
+			{State: "Closed", Events: []EventDesc[*door, *int]{
+				{Event: "Open", Func: retCode, CandList: []string{"Opened", "Closed"}},
+				{Event: "Close", Func: retCode, CandList: []string{"Closed"}},
+				{Event: "Lock", Func: retCode, CandList: []string{"Locked", "Closed"}},
+			}},
+			{State: "Opened", Events: []EventDesc[*door, *int]{
+				{Event: "Open", Func: retCode, CandList: []string{"Opened"}},
+				{Event: "Close", Func: retCode, CandList: []string{"Closed", "Opened"}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,243 @@
+// Package fsmtest generates the event sequences covering every transition of the fsm.Table,
+// and checks the Table wiring by driving the Entries with stub handles
+//
+//	func TestDoorTable(t *testing.T) {
+//	    tbl, _ := fsm.NewTable(&doorDesc)
+//	    fsmtest.Run(t, tbl)
+//	}
+package fsmtest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	fsm "github.com/HaesungSeo/goFSM/v2"
+)
+
+// Step of the Case, the event fired and the expected result
+type Step struct {
+	Event   string            // Event to fire
+	Handle  string            // name of the handle expected to run
+	RetCode fsm.HandleRetCode // return code of the stub handle
+	Active  []fsm.State       // active leaf States expected after the step
+
+	edge int // index of the edge taken
+}
+
+// Case is the shortest event sequence from InitState, the last Step takes the Edge
+type Case struct {
+	Edge  fsm.Edge
+	Steps []Step
+}
+
+// Name returns the name of the Case, "State/Event/Handle/retcode",
+// or "State/Event[Guard]/Handle/retcode" if guarded
+func (c *Case) Name() string {
+	event := c.Edge.Event
+	if c.Edge.Guard != "" {
+		event += "[" + c.Edge.Guard + "]"
+	}
+	return c.Edge.State + "/" + event + "/" + c.Edge.Handle + "/" + strconv.Itoa(int(c.Edge.RetCode))
+}
+
+// Step Error, the Entry did not follow the Case
+type StepError struct {
+	Case   string // name of the Case
+	Step   int    // index of the Step
+	Event  string
+	Reason string
+	Err    error // Error of the transition, if any
+}
+
+func (e *StepError) Error() string {
+	msg := "step mismatch: Case=" + e.Case + ", Step=" + strconv.Itoa(e.Step) +
+		", Event=" + e.Event + ", Reason=" + e.Reason
+	if e.Err != nil {
+		msg += ", Err=" + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// Suite of the Cases covering the Table, not safe for concurrent use
+type Suite[OWNER any, USERDATA any] struct {
+	Cases     []Case     // Cases, in order of fsm.Table.Edges()
+	Uncovered []fsm.Edge // Edges not reachable from InitState
+
+	h *harness[OWNER, USERDATA]
+}
+
+// Generate walks the Table from InitState, and returns the Cases covering every edge of Handle.CandMap
+// the handles and guards of tbl are never called, they are replaced by the stubs,
+// the stub of the Step accepts the event and returns the return code of the Step,
+// the other stubs reject the event if guarded, or return their lowest return code
+// Entry/Exit Actions, Timeouts, deferred events, Listeners and Error Routes are not used
+func Generate[OWNER any, USERDATA any](tbl *fsm.Table[OWNER, USERDATA]) (*Suite[OWNER, USERDATA], error) {
+	h := newHarness(tbl)
+
+	// breadth first search of the Entry configurations
+	type node struct {
+		snap  *fsm.Snapshot
+		steps []Step
+	}
+	start, err := h.entry(nil)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := start.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]interface{}{configKey(snap): nil}
+	queue := []node{{snap: snap}}
+	cases := make(map[int]Case, len(h.edges))
+	events := sortedEvents(tbl)
+
+	for len(queue) > 0 && len(cases) < len(h.edges) {
+		n := queue[0]
+		queue = queue[1:]
+		for _, ev := range events {
+			for i, e := range h.edges {
+				entry, err := h.entry(n.snap)
+				if err != nil {
+					return nil, err
+				}
+				if ran, err := h.fire(entry, ev, i); !ran || err != nil {
+					continue
+				}
+				steps := append(append([]Step{}, n.steps...), Step{
+					Event:   ev,
+					Handle:  e.Handle,
+					RetCode: e.RetCode,
+					Active:  entry.ActiveStates(),
+					edge:    i,
+				})
+				if _, ok := cases[i]; !ok {
+					cases[i] = Case{Edge: e.Edge, Steps: steps}
+				}
+
+				snap, err := entry.Snapshot()
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := seen[configKey(snap)]; !ok {
+					seen[configKey(snap)] = nil
+					queue = append(queue, node{snap: snap, steps: steps})
+				}
+			}
+		}
+	}
+
+	suite := &Suite[OWNER, USERDATA]{h: h}
+	for i, e := range h.edges {
+		if c, ok := cases[i]; ok {
+			suite.Cases = append(suite.Cases, c)
+		} else {
+			suite.Uncovered = append(suite.Uncovered, e.Edge)
+		}
+	}
+	return suite, nil
+}
+
+// Verify drives a new Entry by the Steps of the Case,
+// checks every Step runs the expected handle and reaches the expected active States,
+// and the last Step reaches the next State of the Edge
+func (s *Suite[OWNER, USERDATA]) Verify(c *Case) error {
+	mismatch := func(i int, reason string, err error) error {
+		return &StepError{Case: c.Name(), Step: i, Event: c.Steps[i].Event, Reason: reason, Err: err}
+	}
+
+	entry, err := s.h.entry(nil)
+	if err != nil {
+		return err
+	}
+	for i, step := range c.Steps {
+		ran, err := s.h.fire(entry, step.Event, step.edge)
+		if err != nil {
+			return mismatch(i, "transition failed", err)
+		}
+		if !ran {
+			return mismatch(i, "handle "+step.Handle+" not run", nil)
+		}
+		active := entry.ActiveStates()
+		if !sameStates(active, step.Active) {
+			return mismatch(i, "active states "+names(active)+", expected "+names(step.Active), nil)
+		}
+	}
+
+	last := len(c.Steps) - 1
+	if next := c.Edge.Next; !strings.HasPrefix(next, fsm.HistoryPrefix) && !s.h.within(entry.ActiveStates(), fsm.State{Name: next}) {
+		return mismatch(last, "next state "+next+" not entered", nil)
+	}
+	return nil
+}
+
+// Run verifies every Case as a subtest, and reports the uncovered Edges as errors
+func (s *Suite[OWNER, USERDATA]) Run(t *testing.T) {
+	t.Helper()
+	for _, e := range s.Uncovered {
+		t.Errorf("uncovered edge: %s -> %s: %s", e.State, e.Next, e.Label())
+	}
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		t.Run(c.Name(), func(t *testing.T) {
+			if err := s.Verify(c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Run generates the Suite of the Table and runs it, see Generate()
+func Run[OWNER any, USERDATA any](t *testing.T, tbl *fsm.Table[OWNER, USERDATA]) *Suite[OWNER, USERDATA] {
+	t.Helper()
+	suite, err := Generate(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.Run(t)
+	return suite
+}
+
+// configKey returns the key of the Entry configuration, its active States and history
+func configKey(snap *fsm.Snapshot) string {
+	groups := make([]string, 0, len(snap.History))
+	for name, state := range snap.History {
+		groups = append(groups, name+"="+state)
+	}
+	sort.Strings(groups)
+	return snap.State + "|" + strings.Join(snap.Active, ",") + "|" + strings.Join(groups, ",")
+}
+
+// sortedEvents returns the events of the Table, sorted by name
+func sortedEvents[OWNER any, USERDATA any](tbl *fsm.Table[OWNER, USERDATA]) []string {
+	events := make([]string, 0, len(tbl.Events))
+	for event := range tbl.Events {
+		events = append(events, event.Name)
+	}
+	sort.Strings(events)
+	return events
+}
+
+func sameStates(a []fsm.State, b []fsm.State) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func names(states []fsm.State) string {
+	s := make([]string, 0, len(states))
+	for _, state := range states {
+		s = append(s, state.Name)
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
@@ -0,0 +1,153 @@
+package fsmtest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	fsm "github.com/HaesungSeo/goFSM/v2"
+)
+
+type door struct{}
+
+// the handles and guards are replaced by the stubs, never called
+func handle(d *door, ev fsm.Event, n *int) (fsm.HandleRetCode, error) { panic("never") }
+func guard(d *door, ev fsm.Event, n *int) bool                        { panic("never") }
+
+// doorTable returns the door Table, with Close of Opened transits to closed
+func doorTable(t *testing.T, closed string) *fsm.Table[*door, *int] {
+	t.Helper()
+	tbl, err := fsm.NewTable(&fsm.TableDesc[*door, *int]{
+		InitState:   "Closed",
+		FinalStates: []string{"Broken"},
+		Groups:      []fsm.GroupDesc{{Name: "G", States: []string{"Closed", "Opened"}, Default: "Closed"}},
+		States: []fsm.StateDesc[*door, *int]{
+			{State: "Closed", Events: []fsm.EventDesc[*door, *int]{
+				{Event: "Open", Guard: guard, Func: handle, CandList: []string{"Opened", "Closed"}},
+				{Event: "Open", Func: handle, CandList: []string{"Closed"}},
+				{Event: "Break", Func: handle, CandList: []string{"Maint"}},
+			}},
+			{State: "Opened", Events: []fsm.EventDesc[*door, *int]{
+				{Event: "Open", Func: handle, CandList: []string{"Opened"}},
+				{Event: "Close", Func: handle, CandList: []string{closed}},
+				{Event: "Break", Func: handle, CandList: []string{"Maint"}},
+			}},
+			{State: "Maint", Initial: "Inspect"},
+			{State: "Inspect", Parent: "Maint", Events: []fsm.EventDesc[*door, *int]{
+				{Event: "Break", Func: handle, CandList: []string{"Broken"}},
+				{Event: "Open", Func: handle, CandList: []string{"Inspect"}},
+				{Event: "Close", Func: handle, CandList: []string{fsm.History("G")}},
+			}},
+			{State: fsm.AnyState, Events: []fsm.EventDesc[*door, *int]{
+				{Event: "Close", Func: handle, CandList: []string{"Closed"}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func TestRun(t *testing.T) {
+	tbl := doorTable(t, "Closed")
+	suite := Run(t, tbl)
+	if len(suite.Uncovered) != 0 {
+		t.Fatalf("uncovered %v", suite.Uncovered)
+	}
+	if len(suite.Cases) != len(tbl.Edges()) {
+		t.Fatalf("%d cases, expected %d", len(suite.Cases), len(tbl.Edges()))
+	}
+
+	// the Cases of the guarded edges take the guard
+	var guarded, history bool
+	for _, c := range suite.Cases {
+		guarded = guarded || c.Edge.Guard != ""
+		history = history || c.Edge.Next == fsm.History("G")
+	}
+	if !guarded || !history {
+		t.Fatalf("guarded %v, history %v", guarded, history)
+	}
+}
+
+func TestCoverageUnchanged(t *testing.T) {
+	toggle := func(d *door, ev fsm.Event, n *int) (fsm.HandleRetCode, error) { return fsm.ExitOK, nil }
+	tbl, err := fsm.NewTable(&fsm.TableDesc[*door, *int]{
+		InitState: "A",
+		States: []fsm.StateDesc[*door, *int]{
+			{State: "A", Events: []fsm.EventDesc[*door, *int]{{Event: "T", Func: toggle, CandList: []string{"B"}}}},
+			{State: "B", Events: []fsm.EventDesc[*door, *int]{{Event: "T", Func: toggle, CandList: []string{"A"}}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.EnableCoverage()
+	if _, _, err := tbl.NewEntry(&door{}).Transit("T"); err != nil {
+		t.Fatal(err)
+	}
+	before := tbl.Coverage()
+
+	// the stub transitions are not counted by the Table
+	Run(t, tbl)
+	if after := tbl.Coverage(); !reflect.DeepEqual(after, before) {
+		t.Fatalf("coverage %+v, expected %+v", after, before)
+	}
+}
+
+func TestBrokenWiring(t *testing.T) {
+	good, err := Generate(doorTable(t, "Closed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, err := Generate(doorTable(t, "Opened"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := 0
+	for i := range good.Cases {
+		err := broken.Verify(&good.Cases[i])
+		if err == nil {
+			continue
+		}
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("%s: unexpected error %v", good.Cases[i].Name(), err)
+		}
+		failed++
+	}
+	if failed == 0 {
+		t.Fatal("broken wiring not reported")
+	}
+}
+
+func TestUncovered(t *testing.T) {
+	tbl, err := fsm.NewTable(&fsm.TableDesc[*door, *int]{
+		InitState: "A",
+		States: []fsm.StateDesc[*door, *int]{
+			{State: "A", Events: []fsm.EventDesc[*door, *int]{{Event: "T", Func: handle, CandList: []string{"A"}}}},
+			// hidden by T of A
+			{State: fsm.AnyState, Events: []fsm.EventDesc[*door, *int]{{Event: "T", Func: handle, CandList: []string{"A"}}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite, err := Generate(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suite.Cases) != 1 || len(suite.Uncovered) != 1 || suite.Uncovered[0].State != fsm.AnyState {
+		t.Fatalf("cases %v, uncovered %v", suite.Cases, suite.Uncovered)
+	}
+
+	// the Case not matching the Table
+	c := suite.Cases[0]
+	c.Steps = append([]Step{}, c.Steps...)
+	c.Steps[0].Active = []fsm.State{{Name: "B"}}
+	var stepErr *StepError
+	if err := suite.Verify(&c); !errors.As(err, &stepErr) || stepErr.Step != 0 {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
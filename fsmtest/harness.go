@@ -0,0 +1,148 @@
+package fsmtest
+
+import (
+	"sort"
+
+	fsm "github.com/HaesungSeo/goFSM/v2"
+)
+
+// edge of the Table, one return code of the handle
+type edge[OWNER any, USERDATA any] struct {
+	fsm.Edge
+	handle *fsm.Handle[OWNER, USERDATA] // handle of the Table, not the stub
+	alt    int                          // order of the guarded handle
+}
+
+// harness drives the Entries of the stub Table, a copy of the Table with the stub handles
+type harness[OWNER any, USERDATA any] struct {
+	table *fsm.Table[OWNER, USERDATA]
+	edges []edge[OWNER, USERDATA]
+
+	selected *fsm.Handle[OWNER, USERDATA] // handle of the Table to accept and run
+	code     fsm.HandleRetCode            // return code of the selected handle
+	fired    bool                         // the selected handle ran
+}
+
+func newHarness[OWNER any, USERDATA any](tbl *fsm.Table[OWNER, USERDATA]) *harness[OWNER, USERDATA] {
+	h := &harness[OWNER, USERDATA]{}
+
+	stub := tbl.Clone()
+	stub.LogMax = 0
+	stub.Handles = make(map[fsm.State]map[fsm.Event]*fsm.Handle[OWNER, USERDATA], len(tbl.Handles))
+	stub.Actions = make(map[fsm.State]*fsm.StateAction[OWNER, USERDATA])
+	stub.Timeouts = make(map[fsm.State]*fsm.StateTimeout)
+	stub.Defers = make(map[fsm.State]map[fsm.Event]interface{})
+	stub.Listeners = nil
+	stub.ErrMap = nil
+	stub.PanicPolicy = fsm.PanicStay
+
+	for state, events := range tbl.Handles {
+		stub.Handles[state] = make(map[fsm.Event]*fsm.Handle[OWNER, USERDATA], len(events))
+		for event, handle := range events {
+			var prev *fsm.Handle[OWNER, USERDATA]
+			for alt := 0; handle != nil; alt, handle = alt+1, handle.Alt {
+				s := h.stub(handle)
+				if prev == nil {
+					stub.Handles[state][event] = s
+				} else {
+					prev.Alt = s
+				}
+				prev = s
+
+				for code, next := range handle.CandMap {
+					h.edges = append(h.edges, edge[OWNER, USERDATA]{
+						Edge: fsm.Edge{
+							State:   state.Name,
+							Event:   event.Name,
+							Guard:   handle.GuardName,
+							Handle:  handle.Name,
+							RetCode: code,
+							Next:    next,
+						},
+						handle: handle,
+						alt:    alt,
+					})
+				}
+			}
+		}
+	}
+	h.table = stub
+
+	// in order of fsm.Table.Edges()
+	sort.Slice(h.edges, func(i, j int) bool {
+		a, b := h.edges[i], h.edges[j]
+		if a.State != b.State {
+			return a.State < b.State
+		}
+		if a.Event != b.Event {
+			return a.Event < b.Event
+		}
+		if a.alt != b.alt {
+			return a.alt < b.alt
+		}
+		return a.RetCode < b.RetCode
+	})
+	return h
+}
+
+// stub returns the stub of the handle, without Alt
+func (h *harness[OWNER, USERDATA]) stub(handle *fsm.Handle[OWNER, USERDATA]) *fsm.Handle[OWNER, USERDATA] {
+	lowest := true
+	var fallback fsm.HandleRetCode
+	for code := range handle.CandMap {
+		if lowest || code < fallback {
+			fallback, lowest = code, false
+		}
+	}
+
+	s := &fsm.Handle[OWNER, USERDATA]{
+		Name:      handle.Name,
+		CandMap:   handle.CandMap,
+		GuardName: handle.GuardName,
+	}
+	s.Func = func(owner OWNER, event fsm.Event, userData USERDATA) (fsm.HandleRetCode, error) {
+		if handle == h.selected {
+			h.fired = true
+			return h.code, nil
+		}
+		return fallback, nil
+	}
+	if handle.Guard != nil || handle.GuardCtx != nil {
+		s.Guard = func(owner OWNER, event fsm.Event, userData USERDATA) bool {
+			return handle == h.selected
+		}
+	}
+	return s
+}
+
+// entry returns the new Entry of the stub Table, restored from snap if not nil
+func (h *harness[OWNER, USERDATA]) entry(snap *fsm.Snapshot) (*fsm.Entry[OWNER, USERDATA], error) {
+	var owner OWNER
+	if snap == nil {
+		return h.table.NewEntry(owner), nil
+	}
+	return h.table.RestoreEntry(owner, snap)
+}
+
+// fire transits the Entry by the event, selecting the edge
+// reports the selected handle ran, and returns the error of the transition
+func (h *harness[OWNER, USERDATA]) fire(entry *fsm.Entry[OWNER, USERDATA], ev string, i int) (bool, error) {
+	h.selected, h.code, h.fired = h.edges[i].handle, h.edges[i].RetCode, false
+	defer func() { h.selected = nil }()
+
+	var d USERDATA
+	_, _, err := entry.TransitWithData(ev, d)
+	return h.fired, err
+}
+
+// within reports the State is any of the active States or their ancestors
+func (h *harness[OWNER, USERDATA]) within(active []fsm.State, state fsm.State) bool {
+	for _, leaf := range active {
+		for s, ok := leaf, true; ok; s, ok = h.table.Parents[s] {
+			if s == state {
+				return true
+			}
+		}
+	}
+	return false
+}
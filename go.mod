@@ -1,3 +1,5 @@
 module github.com/HaesungSeo/goFSM/v2
 
-go 1.18
+go 1.21
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,56 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGuards(t *testing.T) {
+	big := func(d *door, ev Event, n *int) bool { return *n > 10 }
+	mid := func(d *door, ev Event, n *int) bool { return *n > 5 }
+	ok := func(d *door, ev Event, n *int) (HandleRetCode, error) { return ExitOK, nil }
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "A",
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []EventDesc[*door, *int]{
+				{Event: "T", Guard: big, Func: ok, CandList: []string{"Big"}},
+				{Event: "T", Guard: mid, Func: ok, CandList: []string{"Mid"}},
+			}},
+			{State: "Big", Events: []EventDesc[*door, *int]{{Event: "T", Func: ok, CandList: []string{"A"}}}},
+			{State: "Mid", Events: []EventDesc[*door, *int]{{Event: "T", Func: ok, CandList: []string{"A"}}}},
+		},
+	})
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	state, eot, err := d.entry.TransitWithData("T", code(1))
+	var rejected *GuardRejected
+	if !errors.As(err, &rejected) || state.Name != "A" || eot {
+		t.Fatalf("state %s eot %v error %v, expected *GuardRejected in A", state.Name, eot, err)
+	}
+
+	// the guards are checked in order of declaration
+	d.entry.TransitWithData("T", code(20))
+	expectState(t, d.entry, "Big")
+	d.entry.TransitWithData("T", code(0))
+	d.entry.TransitWithData("T", code(7))
+	expectState(t, d.entry, "Mid")
+}
+
+func TestGuardAfterUnguarded(t *testing.T) {
+	mid := func(d *door, ev Event, n *int) bool { return *n > 5 }
+	_, err := NewTable(&TableDesc[*door, *int]{
+		InitState: "A",
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []EventDesc[*door, *int]{
+				{Event: "T", Func: retCode, CandList: []string{"A"}},
+				{Event: "T", Guard: mid, Func: retCode, CandList: []string{"A"}},
+			}},
+		},
+	})
+	// the unguarded handle hides the following handles
+	var conflict *StateEventConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("error %v, expected *StateEventConflictError", err)
+	}
+}
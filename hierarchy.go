@@ -0,0 +1,232 @@
+package fsm
+
+import (
+	"context"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// Invalid State Hierarchy Error
+type InvalidHierarchy struct {
+	State  string
+	Reason string
+	Err    error
+}
+
+func (e *InvalidHierarchy) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Reason=" + e.Reason
+}
+
+func (e *InvalidHierarchy) Unwrap() error { return e.Err }
+
+// buildHierarchy indexes StateDesc.Parent, Initial and Parallel of d
+func (tbl *Table[OWNER, USERDATA]) buildHierarchy(d *TableDesc[OWNER, USERDATA]) error {
+	declared := make(map[string]interface{}, len(d.States))
+	for _, state := range d.States {
+		declared[state.State] = nil
+	}
+
+	for _, state := range d.States {
+		if state.Parent == "" {
+			continue
+		}
+		if _, ok := declared[state.Parent]; !ok {
+			return &InvalidHierarchy{
+				State:  state.State,
+				Reason: "undefined parent " + state.Parent,
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+		tbl.Parents[State{state.State}] = State{state.Parent}
+	}
+
+	// check the parent chain ends
+	for child := range tbl.Parents {
+		parent, depth := child, 0
+		for ok := true; ok; parent, ok = tbl.Parents[parent] {
+			if depth > len(tbl.Parents) {
+				return &InvalidHierarchy{
+					State:  child.Name,
+					Reason: "cyclic parent",
+					Err:    fsmerror.ErrInvalidHierarchy,
+				}
+			}
+			depth++
+		}
+	}
+
+	for _, state := range d.States {
+		if parent, ok := tbl.Parents[State{state.State}]; ok && declaredParallel(d, parent.Name) {
+			tbl.Regions[parent] = append(tbl.Regions[parent], State{state.State})
+		}
+	}
+	for _, state := range d.States {
+		if !state.Parallel {
+			continue
+		}
+		if _, ok := tbl.Regions[State{state.State}]; !ok {
+			return &InvalidHierarchy{
+				State:  state.State,
+				Reason: "parallel state without region",
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+		if state.Initial != "" {
+			return &InvalidHierarchy{
+				State:  state.State,
+				Reason: "initial on parallel state",
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+	}
+
+	for _, state := range d.States {
+		if state.Initial == "" {
+			continue
+		}
+		if tbl.Parents[State{state.Initial}] != (State{state.State}) {
+			return &InvalidHierarchy{
+				State:  state.State,
+				Reason: "initial " + state.Initial + " is not a child",
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+		tbl.Initials[State{state.State}] = State{state.Initial}
+	}
+
+	for _, state := range d.States {
+		if state.Timeout <= 0 {
+			continue
+		}
+		if tbl.composite(State{state.State}) {
+			return &InvalidHierarchy{
+				State:  state.State,
+				Reason: "timeout on composite state",
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+		if tbl.inRegion(State{state.State}) {
+			return &InvalidHierarchy{
+				State:  state.State,
+				Reason: "timeout in parallel region",
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+	}
+
+	// composite states entered as the target MUST have the initial child,
+	// and the target in a region MUST be given by the State in the same region
+	if err := tbl.checkEntrance(State{d.InitState}); err != nil {
+		return err
+	}
+	for _, state := range d.States {
+		for _, event := range state.Events {
+			for _, target := range event.nexts() {
+				if err := tbl.checkEntrance(State{target}); err != nil {
+					return err
+				}
+				if err := tbl.checkRegion(State{state.State}, State{target}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// declaredParallel reports the State is declared as parallel State
+func declaredParallel[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA], state string) bool {
+	for _, s := range d.States {
+		if s.State == state {
+			return s.Parallel
+		}
+	}
+	return false
+}
+
+// checkEntrance checks every composite State entered by entering the State has the initial child
+func (tbl *Table[OWNER, USERDATA]) checkEntrance(state State) error {
+	if regions, ok := tbl.Regions[state]; ok {
+		for _, region := range regions {
+			if err := tbl.checkEntrance(region); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !tbl.composite(state) {
+		return nil
+	}
+	initial, ok := tbl.Initials[state]
+	if !ok {
+		return &InvalidHierarchy{
+			State:  state.Name,
+			Reason: "composite state without initial",
+			Err:    fsmerror.ErrInvalidHierarchy,
+		}
+	}
+	return tbl.checkEntrance(initial)
+}
+
+// composite reports the State has any child
+func (tbl *Table[OWNER, USERDATA]) composite(state State) bool {
+	for _, parent := range tbl.Parents {
+		if parent == state {
+			return true
+		}
+	}
+	return false
+}
+
+// path returns the State and its ancestors, from the outermost
+func (tbl *Table[OWNER, USERDATA]) path(state State) []State {
+	depth := 1
+	for s, ok := tbl.Parents[state]; ok; s, ok = tbl.Parents[s] {
+		depth++
+	}
+	path := make([]State, depth)
+	for i, s := depth-1, state; i >= 0; i, s = i-1, tbl.Parents[s] {
+		path[i] = s
+	}
+	return path
+}
+
+// hasHandle reports the State or any of its ancestors handles the event,
+// including the wildcard handles
+func (tbl *Table[OWNER, USERDATA]) hasHandle(state State, event Event) bool {
+	for _, key := range tbl.candidates(state, event) {
+		if _, ok := tbl.Handles[key.state][key.event]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the first handle accepting the event by its guard,
+// searching the State first, then its ancestors from the innermost, then the wildcards,
+// see candidates()
+// found reports any handle of the event exists, even if every guard rejected it
+func (tbl *Table[OWNER, USERDATA]) lookup(ctx context.Context, state State, event Event, owner OWNER, userData USERDATA) (*Handle[OWNER, USERDATA], bool) {
+	found := false
+	for _, key := range tbl.candidates(state, event) {
+		handle, exists := tbl.Handles[key.state][key.event]
+		if !exists {
+			continue
+		}
+		found = true
+		for ; handle != nil; handle = handle.Alt {
+			if handle.accepts(ctx, owner, event, userData) {
+				return handle, true
+			}
+		}
+	}
+	return nil, found
+}
+
+// StatePath returns the current State and its ancestors, from the outermost
+func (e *Entry[OWNER, USERDATA]) StatePath() []State {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.table.path(e.State)
+}
@@ -0,0 +1,91 @@
+package fsm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// connTable has the composite State Conn, Busy is nested in Conn
+func connTable(t *testing.T, trace *[]string) *Table[*door, *int] {
+	enter := func(d *door, s State, ev Event, n *int) error {
+		*trace = append(*trace, "enter "+s.Name)
+		return nil
+	}
+	exit := func(d *door, s State, ev Event, n *int) error {
+		*trace = append(*trace, "exit "+s.Name)
+		return nil
+	}
+	type E = EventDesc[*door, *int]
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "Conn",
+		FinalStates: []string{"Down"},
+		Strict:      true,
+		States: []StateDesc[*door, *int]{
+			{State: "Conn", Initial: "Idle", OnEnter: enter, OnExit: exit,
+				Events: []E{{Event: "Abort", Func: retCode, CandList: []string{"Down"}}}},
+			{State: "Idle", Parent: "Conn", OnEnter: enter, OnExit: exit,
+				Events: []E{{Event: "Go", Func: retCode, CandList: []string{"Busy"}}}},
+			{State: "Busy", Parent: "Conn", Initial: "Work", OnEnter: enter, OnExit: exit},
+			{State: "Work", Parent: "Busy", OnEnter: enter, OnExit: exit,
+				Events: []E{{Event: "Go", Func: retCode, CandList: []string{"Idle"}}}},
+		},
+	})
+}
+
+func names(states []State) string {
+	s := make([]string, 0, len(states))
+	for _, state := range states {
+		s = append(s, state.Name)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestHierarchy(t *testing.T) {
+	var trace []string
+	d := &door{}
+	d.entry = connTable(t, &trace).NewEntry(d)
+
+	// the composite InitState is resolved to its Initial child
+	if path := names(d.entry.StatePath()); path != "Conn Idle" {
+		t.Fatalf("path %s, expected Conn Idle", path)
+	}
+
+	d.entry.Transit("Go")
+	if path := names(d.entry.StatePath()); path != "Conn Busy Work" {
+		t.Fatalf("path %s, expected Conn Busy Work", path)
+	}
+	if s := strings.Join(trace, ", "); s != "exit Idle, enter Busy, enter Work" {
+		t.Fatalf("trace %s, expected the common ancestor Conn kept", s)
+	}
+
+	// Abort is handled by the ancestor, every State is left from the innermost
+	trace = nil
+	state, eot, err := d.entry.Transit("Abort")
+	if err != nil || state.Name != "Down" || !eot {
+		t.Fatalf("state %s eot %v error %v, expected final Down", state.Name, eot, err)
+	}
+	if s := strings.Join(trace, ", "); s != "exit Work, exit Busy, exit Conn" {
+		t.Fatalf("trace %s, expected Work, Busy, Conn left", s)
+	}
+}
+
+func TestInvalidHierarchy(t *testing.T) {
+	type E = EventDesc[*door, *int]
+	for name, states := range map[string][]StateDesc[*door, *int]{
+		"no initial": {
+			{State: "A", Events: []E{{Event: "X", Func: retCode, CandList: []string{"A"}}}},
+			{State: "B", Parent: "A"},
+		},
+		"cycle": {
+			{State: "A", Parent: "B", Events: []E{{Event: "X", Func: retCode, CandList: []string{"A"}}}},
+			{State: "B", Parent: "A"},
+		},
+	} {
+		_, err := NewTable(&TableDesc[*door, *int]{InitState: "A", States: states})
+		var h *InvalidHierarchy
+		if !errors.As(err, &h) {
+			t.Fatalf("%s: error %v, expected *InvalidHierarchy", name, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package fsm
+
+import (
+	"strings"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// prefix of the history target, see History()
+const HistoryPrefix = "history:"
+
+// History returns the next State resolved to the last State the Entry occupied in the group,
+// or the Default of the group if the Entry has not been in the group yet
+// use it in CandList or CandMap, e.g. CandList: []string{fsm.History("Configuring")}
+func History(group string) string {
+	return HistoryPrefix + group
+}
+
+// historyGroup returns the group of the history target
+func historyGroup(target string) (string, bool) {
+	if !strings.HasPrefix(target, HistoryPrefix) {
+		return "", false
+	}
+	return target[len(HistoryPrefix):], true
+}
+
+// FSM State Group Description, see History()
+// the Entry is in the group while it is in any of States or their descendants
+type GroupDesc struct {
+	Name    string   // group name
+	States  []string // States of the group
+	Default string   // State of the history target, if the Entry has not been in the group
+}
+
+// FSM State Group
+type StateGroup struct {
+	Name    string
+	States  map[State]interface{}
+	Default State
+}
+
+// Invalid State Group Error
+type InvalidGroup struct {
+	Group  string
+	Reason string
+	Err    error
+}
+
+func (e *InvalidGroup) Error() string {
+	return e.Err.Error() + ": Group=" + e.Group + ", Reason=" + e.Reason
+}
+
+func (e *InvalidGroup) Unwrap() error { return e.Err }
+
+// Undefined State Group Error, the history target refers the undeclared group
+type UndefinedGroup struct {
+	State string
+	Event string
+	Group string
+	Err   error
+}
+
+func (e *UndefinedGroup) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event + ", Group=" + e.Group
+}
+
+func (e *UndefinedGroup) Unwrap() error { return e.Err }
+
+// buildGroups indexes TableDesc.Groups, and checks the history targets of d
+func (tbl *Table[OWNER, USERDATA]) buildGroups(d *TableDesc[OWNER, USERDATA]) error {
+	for _, g := range d.Groups {
+		if g.Name == "" {
+			return &InvalidGroup{Group: g.Name, Reason: "empty name", Err: fsmerror.ErrInvalidGroup}
+		}
+		if _, ok := tbl.Groups[g.Name]; ok {
+			return &InvalidGroup{Group: g.Name, Reason: "duplicated group", Err: fsmerror.ErrInvalidGroup}
+		}
+		group := &StateGroup{
+			Name:    g.Name,
+			States:  make(map[State]interface{}, len(g.States)),
+			Default: State{g.Default},
+		}
+		for _, state := range g.States {
+			if _, ok := tbl.States[State{state}]; !ok {
+				return &InvalidGroup{Group: g.Name, Reason: "undefined state " + state, Err: fsmerror.ErrInvalidGroup}
+			}
+			if tbl.inRegion(State{state}) {
+				return &InvalidGroup{Group: g.Name, Reason: "state " + state + " in parallel region", Err: fsmerror.ErrInvalidGroup}
+			}
+			group.States[State{state}] = nil
+		}
+		if _, ok := group.States[group.Default]; !ok {
+			return &InvalidGroup{Group: g.Name, Reason: "default " + g.Default + " is not in the group", Err: fsmerror.ErrInvalidGroup}
+		}
+		if err := tbl.checkEntrance(group.Default); err != nil {
+			return err
+		}
+		tbl.Groups[g.Name] = group
+	}
+
+	for _, state := range d.States {
+		for _, event := range state.Events {
+			for _, target := range event.nexts() {
+				name, ok := historyGroup(target)
+				if !ok {
+					continue
+				}
+				if _, ok := tbl.Groups[name]; !ok {
+					return &UndefinedGroup{
+						State: state.State,
+						Event: event.Event,
+						Group: name,
+						Err:   fsmerror.ErrInvalidGroup,
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inGroup reports the State or any of its ancestors is in the group
+func (tbl *Table[OWNER, USERDATA]) inGroup(group *StateGroup, state State) bool {
+	for s, ok := state, true; ok; s, ok = tbl.Parents[s] {
+		if _, in := group.States[s]; in {
+			return true
+		}
+	}
+	return false
+}
+
+// targets returns the States the target may be resolved to
+func (tbl *Table[OWNER, USERDATA]) targets(target string) []State {
+	name, ok := historyGroup(target)
+	if !ok {
+		return []State{{target}}
+	}
+	group := tbl.Groups[name]
+	states := []State{group.Default}
+	for state := range group.States {
+		if state != group.Default {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// resolve resolves the history target to the last State the Entry occupied in the group
+// e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) resolve(target string) State {
+	name, ok := historyGroup(target)
+	if !ok {
+		return State{target}
+	}
+	if state, ok := e.history[name]; ok {
+		return state
+	}
+	return e.table.Groups[name].Default
+}
+
+// remember records the active leaf States as the last State of their groups
+// e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) remember() {
+	if len(e.table.Groups) == 0 {
+		return
+	}
+	for _, leaf := range e.active {
+		for name, group := range e.table.Groups {
+			if e.table.inGroup(group, leaf) {
+				e.history[name] = leaf
+			}
+		}
+	}
+}
+
+// HistoryState returns the last State the Entry occupied in the group,
+// false if the Entry has not been in the group yet
+func (e *Entry[OWNER, USERDATA]) HistoryState(group string) (State, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	state, ok := e.history[group]
+	return state, ok
+}
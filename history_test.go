@@ -0,0 +1,80 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	type E = EventDesc[*door, *int]
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "C1",
+		Groups:    []GroupDesc{{Name: "Cfg", States: []string{"C1", "C2", "C3"}, Default: "C1"}},
+		States: []StateDesc[*door, *int]{
+			{State: "C1", Events: []E{{Event: "Next", Func: retCode, CandList: []string{"C2"}}, {Event: "Suspend", Func: retCode, CandList: []string{"Susp"}}}},
+			{State: "C2", Events: []E{{Event: "Next", Func: retCode, CandList: []string{"C3"}}, {Event: "Suspend", Func: retCode, CandList: []string{"Susp"}}}},
+			{State: "C3", Events: []E{{Event: "Next", Func: retCode, CandList: []string{"C1"}}, {Event: "Suspend", Func: retCode, CandList: []string{"Susp"}}}},
+			{State: "Susp", Events: []E{{Event: "Suspend", Func: retCode, CandList: []string{History("Cfg")}}}},
+		},
+	})
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	d.entry.Transit("Next")
+	d.entry.Transit("Suspend")
+	if last, ok := d.entry.HistoryState("Cfg"); !ok || last.Name != "C2" {
+		t.Fatalf("history %s, expected C2", last.Name)
+	}
+	// resumes the last State of the group
+	state, _, err := d.entry.Transit("Suspend")
+	if err != nil || state.Name != "C2" {
+		t.Fatalf("state %s error %v, expected C2", state.Name, err)
+	}
+
+	// the history is kept by the snapshot
+	d.entry.Transit("Next")
+	d.entry.Transit("Suspend")
+	snap, err := d.entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := tbl.RestoreEntry(&door{}, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _, _ := restored.Transit("Suspend"); state.Name != "C3" {
+		t.Fatalf("restored state %s, expected C3", state.Name)
+	}
+}
+
+func TestHistoryDefault(t *testing.T) {
+	type E = EventDesc[*door, *int]
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "Susp",
+		Groups:    []GroupDesc{{Name: "Cfg", States: []string{"C1", "C2"}, Default: "C2"}},
+		States: []StateDesc[*door, *int]{
+			{State: "C1", Events: []E{{Event: "Resume", Func: retCode, CandList: []string{"C2"}}}},
+			{State: "C2", Events: []E{{Event: "Resume", Func: retCode, CandList: []string{"C1"}}}},
+			{State: "Susp", Events: []E{{Event: "Resume", Func: retCode, CandList: []string{History("Cfg")}}}},
+		},
+	})
+	// the Default, before the group is entered
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	if state, _, _ := d.entry.Transit("Resume"); state.Name != "C2" {
+		t.Fatalf("state %s, expected the default C2", state.Name)
+	}
+}
+
+func TestHistoryUndefinedGroup(t *testing.T) {
+	_, err := NewTable(&TableDesc[*door, *int]{
+		InitState: "Susp",
+		States: []StateDesc[*door, *int]{
+			{State: "Susp", Events: []EventDesc[*door, *int]{{Event: "Resume", Func: retCode, CandList: []string{History("Nope")}}}},
+		},
+	})
+	var u *UndefinedGroup
+	if !errors.As(err, &u) {
+		t.Fatalf("error %T %v, expected *UndefinedGroup", err, err)
+	}
+}
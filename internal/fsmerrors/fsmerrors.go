@@ -3,12 +3,31 @@ package fsmerror
 import "errors"
 
 var (
-	ErrDupHandle       = errors.New("conflict handle")
-	ErrInvalidState    = errors.New("invalid state")
-	ErrInvNextState    = errors.New("invalid next state")
-	ErrInvalidEvent    = errors.New("invalid event")
-	ErrInvalidRetCode  = errors.New("invalid return code")
-	ErrInvalidUserData = errors.New("invalid userdata")
-	ErrHandleNotExists = errors.New("handle not exists")
-	ErrHandleNoRetCode = errors.New("handle has no returncode")
+	ErrDupHandle          = errors.New("conflict handle")
+	ErrInvalidState       = errors.New("invalid state")
+	ErrInvNextState       = errors.New("invalid next state")
+	ErrInvalidEvent       = errors.New("invalid event")
+	ErrInvalidRetCode     = errors.New("invalid return code")
+	ErrInvalidUserData    = errors.New("invalid userdata")
+	ErrHandleNotExists    = errors.New("handle not exists")
+	ErrHandleNoRetCode    = errors.New("handle has no returncode")
+	ErrDupRetCode         = errors.New("duplicated return code")
+	ErrInvalidSpec        = errors.New("invalid specification")
+	ErrAnalysis           = errors.New("table analysis failed")
+	ErrEntryRunning       = errors.New("entry is running")
+	ErrSnapshotVersion    = errors.New("unsupported snapshot version")
+	ErrEntryNotFound      = errors.New("entry not found")
+	ErrPersist            = errors.New("persist failed")
+	ErrStoreCorrupted     = errors.New("store corrupted")
+	ErrGuardRejected      = errors.New("guard rejected")
+	ErrInvalidHierarchy   = errors.New("invalid state hierarchy")
+	ErrInvalidGroup       = errors.New("invalid state group")
+	ErrWildcard           = errors.New("ambiguous wildcard")
+	ErrEventDeferred      = errors.New("event deferred")
+	ErrDeferOverflow      = errors.New("deferred event overflow")
+	ErrTransitCanceled    = errors.New("transition canceled")
+	ErrHandlerPanic       = errors.New("handler panicked")
+	ErrInvalidPanicPolicy = errors.New("invalid panic policy")
+	ErrInvalidErrorRoute  = errors.New("invalid error route")
+	ErrEntryExists        = errors.New("entry exists")
 )
@@ -0,0 +1,117 @@
+package fsm
+
+import (
+	"context"
+	"time"
+)
+
+// FSM Transition information, passed to Listener
+type TransitInfo[OWNER any, USERDATA any] struct {
+	Context  context.Context // context of TransitContext(), context.Background() otherwise
+	Owner    OWNER           // Entry owner
+	ID       string          // Entry ID, see SetStore()
+	State    string          // current State
+	Event    string          // Event
+	Data     USERDATA        // event specific data
+	Handle   string          // Func, empty if no handle found
+	RetCode  HandleRetCode   // Func's return code
+	Next     string          // next State
+	Duration time.Duration   // elapsed time of the transition so far
+	Err      error           // Error of the transition
+}
+
+// FSM Transition Listener
+// called on the goroutine of TransitWithData(), without any lock of the Entry held
+// in parallel States, called per region handling the event, info.State is the active leaf State
+type Listener[OWNER any, USERDATA any] interface {
+	// BeforeTransit is called before Func
+	BeforeTransit(info *TransitInfo[OWNER, USERDATA])
+	// AfterTransit is called after the transition is done, with the result
+	AfterTransit(info *TransitInfo[OWNER, USERDATA])
+	// HandlerError is called if Func returned error
+	HandlerError(info *TransitInfo[OWNER, USERDATA])
+	// UndefinedRetCode is called if Func returned the code not in CandMap
+	UndefinedRetCode(info *TransitInfo[OWNER, USERDATA])
+	// InvalidEvent is called if the event is unknown, or the State has no handle for it
+	// info.Err is *InvalidEvent or *UndefinedHandle
+	InvalidEvent(info *TransitInfo[OWNER, USERDATA])
+}
+
+// NopListener implements Listener doing nothing,
+// embed it to implement only the needed methods
+type NopListener[OWNER any, USERDATA any] struct{}
+
+func (NopListener[OWNER, USERDATA]) BeforeTransit(*TransitInfo[OWNER, USERDATA])    {}
+func (NopListener[OWNER, USERDATA]) AfterTransit(*TransitInfo[OWNER, USERDATA])     {}
+func (NopListener[OWNER, USERDATA]) HandlerError(*TransitInfo[OWNER, USERDATA])     {}
+func (NopListener[OWNER, USERDATA]) UndefinedRetCode(*TransitInfo[OWNER, USERDATA]) {}
+func (NopListener[OWNER, USERDATA]) InvalidEvent(*TransitInfo[OWNER, USERDATA])     {}
+
+// AddListener registers the Listener for all Entries of the Table
+// MUST be called before any transition
+func (tbl *Table[OWNER, USERDATA]) AddListener(l Listener[OWNER, USERDATA]) {
+	tbl.Listeners = append(tbl.Listeners, l)
+}
+
+// AddListener registers the Listener for the Entry
+func (e *Entry[OWNER, USERDATA]) AddListener(l Listener[OWNER, USERDATA]) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, l)
+}
+
+// FSM Transition notifier, collects listeners of the Table and the Entry
+type notifier[OWNER any, USERDATA any] struct {
+	listeners []Listener[OWNER, USERDATA]
+	info      TransitInfo[OWNER, USERDATA]
+	start     time.Time
+	clock     Clock
+}
+
+// newNotifier returns nil if no listener, e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) newNotifier(ctx context.Context, state string, ev string, userData USERDATA) *notifier[OWNER, USERDATA] {
+	if len(e.table.Listeners) == 0 && len(e.listeners) == 0 {
+		return nil
+	}
+	n := &notifier[OWNER, USERDATA]{
+		listeners: make([]Listener[OWNER, USERDATA], 0, len(e.table.Listeners)+len(e.listeners)),
+		start:     e.table.Clock.Now(),
+		clock:     e.table.Clock,
+	}
+	n.listeners = append(n.listeners, e.table.Listeners...)
+	n.listeners = append(n.listeners, e.listeners...)
+	n.info = TransitInfo[OWNER, USERDATA]{
+		Context: ctx,
+		Owner:   e.Owner,
+		ID:      e.id,
+		State:   state,
+		Event:   ev,
+		Data:    userData,
+		Next:    state,
+	}
+	return n
+}
+
+// fork returns the copy of the notifier for the transition of the active leaf State
+func (n *notifier[OWNER, USERDATA]) fork(state string) *notifier[OWNER, USERDATA] {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.info.State = state
+	c.info.Next = state
+	return &c
+}
+
+// notify calls f of every listener, nil notifier does nothing
+// f is the method expression of Listener, e.g. Listener[OWNER, USERDATA].AfterTransit
+func (n *notifier[OWNER, USERDATA]) notify(f func(l Listener[OWNER, USERDATA], info *TransitInfo[OWNER, USERDATA])) {
+	if n == nil {
+		return
+	}
+	n.info.Duration = n.clock.Now().Sub(n.start)
+	for _, l := range n.listeners {
+		info := n.info
+		f(l, &info)
+	}
+}
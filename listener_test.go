@@ -0,0 +1,76 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// recorder records the calls of the Listener
+type recorder struct {
+	name  string
+	calls *[]string
+	infos []TransitInfo[*door, *int]
+}
+
+func (r *recorder) record(method string, info *TransitInfo[*door, *int]) {
+	*r.calls = append(*r.calls, r.name+"."+method)
+	r.infos = append(r.infos, *info)
+}
+
+func (r *recorder) BeforeTransit(info *TransitInfo[*door, *int])    { r.record("Before", info) }
+func (r *recorder) AfterTransit(info *TransitInfo[*door, *int])     { r.record("After", info) }
+func (r *recorder) HandlerError(info *TransitInfo[*door, *int])     { r.record("Error", info) }
+func (r *recorder) UndefinedRetCode(info *TransitInfo[*door, *int]) { r.record("RetCode", info) }
+func (r *recorder) InvalidEvent(info *TransitInfo[*door, *int])     { r.record("Invalid", info) }
+
+func TestListeners(t *testing.T) {
+	errFail := errors.New("failed")
+	handle := func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		if *n < 0 {
+			return ExitOK, errFail
+		}
+		return HandleRetCode(*n), nil
+	}
+	desc := doorDesc()
+	desc.States[0].Events[0].Func = handle
+	tbl := newTestTable(t, desc)
+
+	var calls []string
+	tl := &recorder{name: "table", calls: &calls}
+	el := &recorder{name: "entry", calls: &calls}
+	tbl.AddListener(tl)
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	d.entry.AddListener(el)
+
+	expect := func(expected ...string) {
+		t.Helper()
+		if len(calls) != len(expected) {
+			t.Fatalf("calls %v, expected %v", calls, expected)
+		}
+		for i := range expected {
+			if calls[i] != expected[i] {
+				t.Fatalf("calls %v, expected %v", calls, expected)
+			}
+		}
+		calls = nil
+	}
+
+	d.entry.TransitWithData("Open", code(-1))
+	expect("table.Before", "entry.Before", "table.Error", "entry.Error", "table.After", "entry.After")
+	if info := tl.infos[len(tl.infos)-1]; !errors.Is(info.Err, errFail) || info.State != "Closed" {
+		t.Fatalf("info %+v, expected the handler error in Closed", info)
+	}
+
+	d.entry.TransitWithData("Open", code(7))
+	expect("table.Before", "entry.Before", "table.RetCode", "entry.RetCode", "table.After", "entry.After")
+
+	d.entry.Transit("Kick")
+	expect("table.Invalid", "entry.Invalid")
+
+	d.entry.TransitWithData("Open", code(0))
+	expect("table.Before", "entry.Before", "table.After", "entry.After")
+	if info := el.infos[len(el.infos)-1]; info.Next != "Opened" || info.Err != nil || info.Handle == "" {
+		t.Fatalf("info %+v, expected Closed to Opened", info)
+	}
+}
@@ -0,0 +1,255 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"sync"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// default number of shards of the Manager
+const DefaultShards = 64
+
+// Entry Exists Error
+type EntryExists struct {
+	ID  string
+	Err error
+}
+
+func (e *EntryExists) Error() string {
+	return e.Err.Error() + ": ID=" + e.ID
+}
+
+func (e *EntryExists) Unwrap() error { return e.Err }
+
+// FSM Entry Manager, Entries of the Table indexed by key
+// the keys are spread over the shards, each guarded by its own lock
+// the Entry reaching the final States is removed automatically,
+// however it is transited or restored, see Dispatch()
+type Manager[K comparable, OWNER any, USERDATA any] struct {
+	table  *Table[OWNER, USERDATA]
+	shards []shard[K, OWNER, USERDATA]
+	hash   func(K) uint64
+}
+
+// Entries of the Manager, guarded by mu
+type shard[K comparable, OWNER any, USERDATA any] struct {
+	mu      sync.RWMutex
+	entries map[K]*Entry[OWNER, USERDATA]
+}
+
+// Create New FSM Entry Manager
+// shards is the number of shards, DefaultShards if 0
+// hash maps the key to the shard, if nil, string and integer keys are hashed directly,
+// and others by fmt.Sprint()
+func NewManager[K comparable, OWNER any, USERDATA any](tbl *Table[OWNER, USERDATA], shards int, hash func(K) uint64) *Manager[K, OWNER, USERDATA] {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	if hash == nil {
+		seed := maphash.MakeSeed()
+		hash = func(key K) uint64 { return hashKey(seed, key) }
+	}
+	m := &Manager[K, OWNER, USERDATA]{
+		table:  tbl,
+		shards: make([]shard[K, OWNER, USERDATA], shards),
+		hash:   hash,
+	}
+	for i := range m.shards {
+		m.shards[i].entries = make(map[K]*Entry[OWNER, USERDATA])
+	}
+	return m
+}
+
+// hashKey hashes the key, without formatting string and integer keys
+func hashKey[K comparable](seed maphash.Seed, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(seed, k)
+	case int:
+		return uint64(k)
+	case int64:
+		return uint64(k)
+	case int32:
+		return uint64(k)
+	case uint:
+		return uint64(k)
+	case uint64:
+		return k
+	case uint32:
+		return uint64(k)
+	}
+	return maphash.String(seed, fmt.Sprint(key))
+}
+
+// shard returns the shard of the key
+func (m *Manager[K, OWNER, USERDATA]) shard(key K) *shard[K, OWNER, USERDATA] {
+	return &m.shards[m.hash(key)%uint64(len(m.shards))]
+}
+
+// Table returns the Table of the Manager
+func (m *Manager[K, OWNER, USERDATA]) Table() *Table[OWNER, USERDATA] {
+	return m.table
+}
+
+// Create creates the Entry of the key, see Table.NewSyncEntry()
+// the Entry starting in the final States is returned, but not kept, as Dispatch() removes it
+// returns *EntryExists if the key has the Entry already
+func (m *Manager[K, OWNER, USERDATA]) Create(key K, owner OWNER) (*Entry[OWNER, USERDATA], error) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; ok {
+		return nil, &EntryExists{ID: fmt.Sprint(key), Err: fsmerror.ErrEntryExists}
+	}
+	entry := m.table.NewSyncEntry(owner)
+	if entry.finished() {
+		entry.StopTimer()
+		return entry, nil
+	}
+	entry.AddListener(&evictor[K, OWNER, USERDATA]{manager: m, key: key, entry: entry})
+	s.entries[key] = entry
+	return entry, nil
+}
+
+// Get returns the Entry of the key, false if not exists
+func (m *Manager[K, OWNER, USERDATA]) Get(key K) (*Entry[OWNER, USERDATA], bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+// Remove removes the Entry of the key, false if not exists
+// the removed Entry still works, but is not managed any more,
+// and its state timeout is stopped, see StopTimer()
+func (m *Manager[K, OWNER, USERDATA]) Remove(key K) bool {
+	s := m.shard(key)
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	delete(s.entries, key)
+	s.mu.Unlock()
+
+	if ok {
+		entry.StopTimer()
+	}
+	return ok
+}
+
+// evict removes the Entry of the key, if the key still has the Entry, and stops its state timeout
+func (m *Manager[K, OWNER, USERDATA]) evict(key K, entry *Entry[OWNER, USERDATA]) {
+	s := m.shard(key)
+	s.mu.Lock()
+	if s.entries[key] == entry {
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+
+	entry.StopTimer()
+}
+
+// Dispatch does FSM of the Entry of the key, see TransitWithData()
+// the Entry is removed if all its active States are final States
+// returns *EntryNotFound if the key has no Entry
+func (m *Manager[K, OWNER, USERDATA]) Dispatch(key K, ev string, userData USERDATA) (State, bool, error) {
+	return m.DispatchContext(context.Background(), key, ev, userData)
+}
+
+// DispatchContext does FSM of the Entry of the key with the context, see TransitContext()
+func (m *Manager[K, OWNER, USERDATA]) DispatchContext(ctx context.Context, key K, ev string, userData USERDATA) (State, bool, error) {
+	entry, ok := m.Get(key)
+	if !ok {
+		return State{}, false, &EntryNotFound{ID: fmt.Sprint(key), Err: fsmerror.ErrEntryNotFound}
+	}
+	return entry.TransitContext(ctx, ev, userData)
+}
+
+// Len returns the number of the Entries
+func (m *Manager[K, OWNER, USERDATA]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.entries)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Range calls f for every Entry, until f returns false
+// f is called without any lock held, so it may Dispatch() or Remove(),
+// the Entries created or removed during Range() may or may not be visited
+func (m *Manager[K, OWNER, USERDATA]) Range(f func(key K, entry *Entry[OWNER, USERDATA]) bool) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		keys := make([]K, 0, len(s.entries))
+		entries := make([]*Entry[OWNER, USERDATA], 0, len(s.entries))
+		for key, entry := range s.entries {
+			keys = append(keys, key)
+			entries = append(entries, entry)
+		}
+		s.mu.RUnlock()
+
+		for j := range keys {
+			if !f(keys[j], entries[j]) {
+				return
+			}
+		}
+	}
+}
+
+// RangeState calls f for every Entry in the State, until f returns false, see Range()
+// the State is compared with CurrentState() of the Entry
+func (m *Manager[K, OWNER, USERDATA]) RangeState(state State, f func(key K, entry *Entry[OWNER, USERDATA]) bool) {
+	m.Range(func(key K, entry *Entry[OWNER, USERDATA]) bool {
+		if entry.CurrentState() != state {
+			return true
+		}
+		return f(key, entry)
+	})
+}
+
+// CountByState returns the number of the Entries indexed by CurrentState() of the Entry
+func (m *Manager[K, OWNER, USERDATA]) CountByState() map[State]int {
+	counts := make(map[State]int)
+	m.Range(func(key K, entry *Entry[OWNER, USERDATA]) bool {
+		counts[entry.CurrentState()]++
+		return true
+	})
+	return counts
+}
+
+// Listener of the managed Entry, removes the Entry reaching the final States,
+// by a transition or by Restore()
+type evictor[K comparable, OWNER any, USERDATA any] struct {
+	NopListener[OWNER, USERDATA]
+	manager *Manager[K, OWNER, USERDATA]
+	key     K
+	entry   *Entry[OWNER, USERDATA]
+}
+
+func (l *evictor[K, OWNER, USERDATA]) AfterTransit(*TransitInfo[OWNER, USERDATA]) {
+	l.afterRestore()
+}
+
+func (l *evictor[K, OWNER, USERDATA]) afterRestore() {
+	if l.entry.finished() {
+		l.manager.evict(l.key, l.entry)
+	}
+}
+
+// restoreListener is the Listener notified after Entry.Restore()
+type restoreListener interface {
+	afterRestore()
+}
+
+// finished reports all active States of the Entry are final States
+func (e *Entry[OWNER, USERDATA]) finished() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.table.final(e.active)
+}
@@ -0,0 +1,139 @@
+package fsm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// endTable moves A and B by T, or ends by the return code 1
+func endTable(t *testing.T, clock Clock) *Table[*door, *int] {
+	type E = EventDesc[*door, *int]
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "A",
+		FinalStates: []string{"End"},
+		Clock:       clock,
+		States: []StateDesc[*door, *int]{
+			{State: "A", Timeout: time.Minute, TimeoutEvent: "T", Events: []E{{Event: "T", Func: retCode, CandList: []string{"B", "End"}}}},
+			{State: "B", Events: []E{{Event: "T", Func: retCode, CandList: []string{"A", "End"}}}},
+		},
+	})
+}
+
+func TestManagerDispatch(t *testing.T) {
+	const n = 1000
+	m := NewManager[int](endTable(t, NewFakeClock(time.Unix(0, 0))), 0, nil)
+	for i := 0; i < n; i++ {
+		if _, err := m.Create(i, &door{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var exists *EntryExists
+	if _, err := m.Create(5, &door{}); !errors.As(err, &exists) {
+		t.Fatalf("error %v, expected *EntryExists", err)
+	}
+
+	// the odd keys end, and are evicted
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, _, err := m.Dispatch(i, "T", code(i%2)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if m.Len() != n/2 {
+		t.Fatalf("%d entries, expected %d", m.Len(), n/2)
+	}
+	if c := m.CountByState(); len(c) != 1 || c[State{"B"}] != n/2 {
+		t.Fatalf("counts %v, expected all in B", c)
+	}
+	visited := 0
+	m.RangeState(State{"B"}, func(key int, entry *Entry[*door, *int]) bool {
+		visited++
+		return visited < 10
+	})
+	if visited != 10 {
+		t.Fatalf("%d visited, expected to stop at 10", visited)
+	}
+	var nf *EntryNotFound
+	if _, _, err := m.Dispatch(1, "T", code(0)); !errors.As(err, &nf) {
+		t.Fatalf("error %v, expected *EntryNotFound of the evicted", err)
+	}
+}
+
+func TestManagerStopsTimers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	type E = EventDesc[*door, *int]
+	// the final State End has the timeout too
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "A",
+		FinalStates: []string{"End"},
+		Clock:       clock,
+		States: []StateDesc[*door, *int]{
+			{State: "A", Timeout: time.Minute, TimeoutEvent: "T", Events: []E{{Event: "T", Func: retCode, CandList: []string{"End"}}}},
+			{State: "End", Timeout: time.Minute, TimeoutEvent: "T", Events: []E{{Event: "T", Func: retCode, CandList: []string{"End"}}}},
+		},
+	})
+	m := NewManager[string](tbl, 4, nil)
+	m.Create("removed", &door{})
+	m.Create("evicted", &door{})
+	if clock.Timers() != 2 {
+		t.Fatalf("%d timers, expected 2", clock.Timers())
+	}
+
+	if !m.Remove("removed") || m.Remove("removed") {
+		t.Fatal("Remove() reported wrong existence")
+	}
+	if clock.Timers() != 1 {
+		t.Fatalf("%d timers, expected the timer of the removed Entry stopped", clock.Timers())
+	}
+
+	if _, eot, _ := m.Dispatch("evicted", "T", code(0)); !eot {
+		t.Fatal("expected the end of transition")
+	}
+	if clock.Timers() != 0 || m.Len() != 0 {
+		t.Fatalf("%d timers %d entries, expected the evicted Entry stopped", clock.Timers(), m.Len())
+	}
+}
+
+func TestManagerEvictsFinal(t *testing.T) {
+	// the Entry starting in the final State is not kept
+	desc := doorDesc()
+	desc.FinalStates = append(desc.FinalStates, "Closed")
+	m := NewManager[int](newTestTable(t, desc), 0, nil)
+	entry, err := m.Create(1, &door{})
+	if err != nil || entry == nil {
+		t.Fatalf("entry %v error %v", entry, err)
+	}
+	if _, ok := m.Get(1); ok || m.Len() != 0 {
+		t.Fatalf("%d entries, expected the final Entry not kept", m.Len())
+	}
+
+	// the Entry restored into the final State is removed
+	tbl := newTestTable(t, doorDesc())
+	m = NewManager[int](tbl, 0, nil)
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	d.entry.Transit("Lock")
+	snap, err := d.entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err = m.Create(2, &door{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	expectState(t, entry, "Locked")
+	if _, ok := m.Get(2); ok || m.Len() != 0 {
+		t.Fatalf("%d entries, expected the restored final Entry removed", m.Len())
+	}
+}
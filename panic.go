@@ -0,0 +1,100 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// FSM Handle Panic Policy, what the Entry does when the handle panics
+type PanicPolicy string
+
+// FSM Handle Panic Policy Enumeration
+const (
+	PanicStay    PanicPolicy = "stay"    // stay in the current State, the default
+	PanicRoute   PanicPolicy = "route"   // move to TableDesc.PanicState
+	PanicRepanic PanicPolicy = "repanic" // panic again with the recovered value, after the log is recorded
+)
+
+// Handler Panic Error, the handle panicked
+type HandlerPanic struct {
+	State  string
+	Event  string
+	Handle string
+	Value  interface{} // value recovered from the panic
+	Stack  []byte      // stack trace of the panicked goroutine
+	Err    error
+}
+
+func (e *HandlerPanic) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event +
+		", Handle=" + e.Handle + ", Value=" + fmt.Sprint(e.Value)
+}
+
+func (e *HandlerPanic) Unwrap() error { return e.Err }
+
+// Invalid Panic Policy Error
+type InvalidPanicPolicy struct {
+	Policy string
+	State  string // PanicState
+	Reason string
+	Err    error
+}
+
+func (e *InvalidPanicPolicy) Error() string {
+	return e.Err.Error() + ": Policy=" + e.Policy + ", State=" + e.State + ", Reason=" + e.Reason
+}
+
+func (e *InvalidPanicPolicy) Unwrap() error { return e.Err }
+
+// checkPanicPolicy checks PanicPolicy and PanicState of d
+func (tbl *Table[OWNER, USERDATA]) checkPanicPolicy(d *TableDesc[OWNER, USERDATA]) error {
+	invalid := func(reason string) error {
+		return &InvalidPanicPolicy{
+			Policy: string(d.PanicPolicy),
+			State:  d.PanicState,
+			Reason: reason,
+			Err:    fsmerror.ErrInvalidPanicPolicy,
+		}
+	}
+
+	switch d.PanicPolicy {
+	case "", PanicStay, PanicRepanic:
+		if d.PanicState != "" {
+			return invalid("panic state without route policy")
+		}
+		return nil
+	case PanicRoute:
+	default:
+		return invalid("unknown policy")
+	}
+
+	if _, ok := tbl.States[State{d.PanicState}]; !ok {
+		return invalid("undefined panic state")
+	}
+	if tbl.inRegion(State{d.PanicState}) {
+		return invalid("panic state in parallel region")
+	}
+	return tbl.checkEntrance(State{d.PanicState})
+}
+
+// protect runs the handle, recovers the panic of the handle as hp, err is hp then
+func (e *Entry[OWNER, USERDATA]) protect(ctx context.Context, handle *Handle[OWNER, USERDATA], state string, event Event, userData USERDATA) (retCode HandleRetCode, hp *HandlerPanic, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			hp = &HandlerPanic{
+				State:  state,
+				Event:  event.Name,
+				Handle: handle.Name,
+				Value:  v,
+				Stack:  debug.Stack(),
+				Err:    fsmerror.ErrHandlerPanic,
+			}
+			retCode, err = 0, hp
+		}
+	}()
+	retCode, err = handle.call(ctx, e.Owner, event, userData)
+	return retCode, nil, err
+}
@@ -0,0 +1,97 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// panicDesc returns the Table of the handle panicking for the return code 1
+func panicDesc(policy PanicPolicy, state string) *TableDesc[*door, *int] {
+	handle := func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		if *n == 1 {
+			panic("boom")
+		}
+		return ExitOK, nil
+	}
+	type E = EventDesc[*door, *int]
+	return &TableDesc[*door, *int]{
+		InitState:   "A",
+		FinalStates: []string{"Failed"},
+		LogMax:      10,
+		PanicPolicy: policy,
+		PanicState:  state,
+		States: []StateDesc[*door, *int]{
+			{State: "A", Events: []E{{Event: "T", Func: handle, CandList: []string{"B"}}}},
+			{State: "B", Events: []E{{Event: "T", Func: handle, CandList: []string{"A"}}}},
+			{State: "Failed"},
+		},
+	}
+}
+
+func TestPanicStay(t *testing.T) {
+	d := &door{}
+	d.entry = newTestTable(t, panicDesc("", "")).NewEntry(d)
+
+	state, eot, err := d.entry.TransitWithData("T", code(1))
+	var hp *HandlerPanic
+	if !errors.As(err, &hp) || hp.Value != "boom" || len(hp.Stack) == 0 {
+		t.Fatalf("error %v, expected *HandlerPanic with the stack", err)
+	}
+	if state.Name != "A" || eot {
+		t.Fatalf("state %s eot %v, expected to stay in A", state.Name, eot)
+	}
+}
+
+func TestPanicRoute(t *testing.T) {
+	tbl := newTestTable(t, panicDesc(PanicRoute, "Failed"))
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	state, eot, err := d.entry.TransitWithData("T", code(1))
+	var hp *HandlerPanic
+	if !errors.As(err, &hp) || state.Name != "Failed" || !eot {
+		t.Fatalf("state %s eot %v error %v, expected final Failed", state.Name, eot, err)
+	}
+	// PanicState is reachable
+	if a := tbl.Analyze(); !a.OK() {
+		t.Fatalf("analysis %+v, expected no finding", a)
+	}
+}
+
+func TestPanicRepanic(t *testing.T) {
+	d := &door{}
+	d.entry = newTestTable(t, panicDesc(PanicRepanic, "")).NewSyncEntry(d)
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Fatalf("recovered %v, expected boom", v)
+			}
+		}()
+		d.entry.TransitWithData("T", code(1))
+	}()
+	if logs := d.entry.LogRecords(0); len(logs) != 1 || logs[0].Err == "" {
+		t.Fatalf("logs %+v, expected the panic logged", logs)
+	}
+	// the sync Entry is not left locked
+	if state, _, err := d.entry.TransitWithData("T", code(0)); err != nil || state.Name != "B" {
+		t.Fatalf("state %s error %v, expected B", state.Name, err)
+	}
+}
+
+func TestInvalidPanicPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy PanicPolicy
+		state  string
+	}{
+		{PanicRoute, "Unknown"},
+		{PanicStay, "Failed"},
+		{"bogus", ""},
+	} {
+		_, err := NewTable(panicDesc(c.policy, c.state))
+		var ip *InvalidPanicPolicy
+		if !errors.As(err, &ip) {
+			t.Fatalf("policy %q state %q: error %v, expected *InvalidPanicPolicy", c.policy, c.state, err)
+		}
+	}
+}
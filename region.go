@@ -0,0 +1,189 @@
+package fsm
+
+import (
+	"context"
+	"sort"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// inRegion reports the State is in a region of any parallel State
+func (tbl *Table[OWNER, USERDATA]) inRegion(state State) bool {
+	for s, ok := tbl.Parents[state]; ok; s, ok = tbl.Parents[s] {
+		if _, parallel := tbl.Regions[s]; parallel {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRegion checks the target in a region is given by the State in the same region
+func (tbl *Table[OWNER, USERDATA]) checkRegion(state State, target State) error {
+	src := tbl.path(state)
+	dst := tbl.path(target)
+	for i := 0; i < len(dst)-1; i++ {
+		if _, parallel := tbl.Regions[dst[i]]; !parallel {
+			continue
+		}
+		region := dst[i+1]
+		if len(src) <= i+1 || src[i+1] != region {
+			return &InvalidHierarchy{
+				State:  state.Name,
+				Reason: "target " + target.Name + " crosses regions of " + dst[i].Name,
+				Err:    fsmerror.ErrInvalidHierarchy,
+			}
+		}
+	}
+	return nil
+}
+
+// descend appends the States entered by entering the State to enters, from the outermost,
+// and the leaf States to leaves
+// the initial child of the composite State is entered, or every region of the parallel State
+func (tbl *Table[OWNER, USERDATA]) descend(state State, enters []State, leaves []State) ([]State, []State) {
+	if regions, ok := tbl.Regions[state]; ok {
+		for _, region := range regions {
+			enters = append(enters, region)
+			enters, leaves = tbl.descend(region, enters, leaves)
+		}
+		return enters, leaves
+	}
+	if initial, ok := tbl.Initials[state]; ok {
+		enters = append(enters, initial)
+		return tbl.descend(initial, enters, leaves)
+	}
+	return enters, append(leaves, state)
+}
+
+// leaves returns the leaf States entered by entering the State
+func (tbl *Table[OWNER, USERDATA]) leaves(state State) []State {
+	_, leaves := tbl.descend(state, nil, nil)
+	return leaves
+}
+
+// container returns the innermost State containing all the leaf States
+func (tbl *Table[OWNER, USERDATA]) container(leaves []State) State {
+	common := tbl.path(leaves[0])
+	for _, leaf := range leaves[1:] {
+		path := tbl.path(leaf)
+		n := 0
+		for n < len(common) && n < len(path) && common[n] == path[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) == 0 {
+		return State{}
+	}
+	return common[len(common)-1]
+}
+
+// handledOnEntry reports any leaf State entered by the target handles the event
+func (tbl *Table[OWNER, USERDATA]) handledOnEntry(target State, event Event) bool {
+	for _, leaf := range tbl.leaves(target) {
+		if tbl.hasHandle(leaf, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// transition returns the States to leave, from the innermost,
+// the States to enter, from the outermost, and the next active leaf States,
+// for the transition from the active leaf State to the target State
+// the common ancestors are neither left nor entered,
+// the target which is an ancestor of the leaf State leaves and enters its descendants only
+func (tbl *Table[OWNER, USERDATA]) transition(active []State, from State, target State) (exits []State, enters []State, next []State) {
+	src, dst := tbl.path(from), tbl.path(target)
+	common := 0
+	for common < len(src) && common < len(dst) && src[common] == dst[common] {
+		common++
+	}
+
+	// leave the active States under the anchor, from the depth
+	anchor, depth := common, common
+	if common == len(dst) {
+		anchor = common - 1
+	}
+	enters, leaves := tbl.descend(target, append([]State{}, dst[common:]...), nil)
+
+	left := make([]State, 0, len(active))
+	next = make([]State, 0, len(active)+len(leaves))
+	seen := make(map[State]interface{})
+	for _, leaf := range active {
+		path := tbl.path(leaf)
+		if len(path) <= anchor || path[anchor] != src[anchor] {
+			next = append(next, leaf)
+			continue
+		}
+		if len(left) == 0 {
+			next = append(next, leaves...)
+		}
+		left = append(left, leaf)
+		for i := len(path) - 1; i >= depth; i-- {
+			if _, ok := seen[path[i]]; !ok {
+				seen[path[i]] = nil
+				exits = append(exits, path[i])
+			}
+		}
+	}
+
+	if len(left) == 1 && len(leaves) == 1 && left[0] == from && leaves[0] == from {
+		// the same State
+		return nil, nil, active
+	}
+
+	// descendants first
+	sort.SliceStable(exits, func(i, j int) bool {
+		return len(tbl.path(exits[i])) > len(tbl.path(exits[j]))
+	})
+	return exits, enters, next
+}
+
+// step of the transition, the handle for the active leaf State
+type step[OWNER any, USERDATA any] struct {
+	from   State
+	handle *Handle[OWNER, USERDATA]
+}
+
+// dispatch returns the handles accepting the event for the active leaf States,
+// the handle shared by several regions is returned once, for the first active State
+// found reports any handle of the event exists, even if every guard rejected it
+func (tbl *Table[OWNER, USERDATA]) dispatch(ctx context.Context, active []State, event Event, owner OWNER, userData USERDATA) ([]step[OWNER, USERDATA], bool) {
+	steps := make([]step[OWNER, USERDATA], 0, len(active))
+	found := false
+	for _, leaf := range active {
+		handle, exists := tbl.lookup(ctx, leaf, event, owner, userData)
+		found = found || exists
+		if handle == nil {
+			continue
+		}
+		shared := false
+		for _, s := range steps {
+			shared = shared || s.handle == handle
+		}
+		if !shared {
+			steps = append(steps, step[OWNER, USERDATA]{from: leaf, handle: handle})
+		}
+	}
+	return steps, found
+}
+
+// final reports every active leaf State is a final State
+func (tbl *Table[OWNER, USERDATA]) final(active []State) bool {
+	for _, leaf := range active {
+		if _, ok := tbl.FSMap[leaf.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ActiveStates returns the active leaf States, one per region of the parallel States,
+// in order of declaration of the regions
+// the Entry not in any parallel State has one active State, the current State
+func (e *Entry[OWNER, USERDATA]) ActiveStates() []State {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]State{}, e.active...)
+}
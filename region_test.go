@@ -0,0 +1,91 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// deviceTable has the parallel State Device of the regions Power and Link
+func deviceTable(t *testing.T) *Table[*door, *int] {
+	type E = EventDesc[*door, *int]
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "Boot",
+		FinalStates: []string{"PowerDone", "LinkDone", "Dead"},
+		States: []StateDesc[*door, *int]{
+			{State: "Boot", Events: []E{{Event: "Start", Func: retCode, CandList: []string{"Device"}}}},
+			{State: "Device", Parallel: true, Events: []E{
+				{Event: "Kill", Func: retCode, CandList: []string{"Dead"}},
+				{Event: "Start", Func: retCode, CandList: []string{"Dead"}},
+			}},
+			{State: "Power", Parent: "Device", Initial: "Off"},
+			{State: "Off", Parent: "Power", Events: []E{{Event: "Up", Func: retCode, CandList: []string{"On"}}}},
+			{State: "On", Parent: "Power", Events: []E{
+				{Event: "Up", Func: retCode, CandList: []string{"On"}},
+				{Event: "Fin", Func: retCode, CandList: []string{"PowerDone"}},
+			}},
+			{State: "PowerDone", Parent: "Power"},
+			{State: "Link", Parent: "Device", Initial: "Down"},
+			{State: "Down", Parent: "Link", Events: []E{
+				{Event: "Up", Func: retCode, CandList: []string{"Down"}},
+				{Event: "Fin", Func: retCode, CandList: []string{"LinkDone"}},
+			}},
+			{State: "LinkDone", Parent: "Link"},
+		},
+	})
+}
+
+func TestRegions(t *testing.T) {
+	tbl := deviceTable(t)
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	state, _, _ := d.entry.Transit("Start")
+	if state.Name != "Device" || names(d.entry.ActiveStates()) != "Off Down" {
+		t.Fatalf("state %s active %s, expected Device of Off Down", state.Name, names(d.entry.ActiveStates()))
+	}
+
+	// every region handles the event
+	d.entry.Transit("Up")
+	if active := names(d.entry.ActiveStates()); active != "On Down" {
+		t.Fatalf("active %s, expected On Down", active)
+	}
+	snap, err := d.entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the end of transition, when every region is in the final State
+	state, eot, err := d.entry.Transit("Fin")
+	if err != nil || !eot || names(d.entry.ActiveStates()) != "PowerDone LinkDone" {
+		t.Fatalf("state %s eot %v error %v, expected every region done", state.Name, eot, err)
+	}
+
+	// the restored Entry keeps the regions, the parallel State handles Kill
+	restored, err := tbl.RestoreEntry(&door{}, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active := names(restored.ActiveStates()); active != "On Down" {
+		t.Fatalf("restored active %s, expected On Down", active)
+	}
+	state, eot, _ = restored.Transit("Kill")
+	if state.Name != "Dead" || !eot || names(restored.ActiveStates()) != "Dead" {
+		t.Fatalf("state %s eot %v, expected Dead", state.Name, eot)
+	}
+}
+
+func TestRegionCrossTarget(t *testing.T) {
+	type E = EventDesc[*door, *int]
+	_, err := NewTable(&TableDesc[*door, *int]{
+		InitState: "Device",
+		States: []StateDesc[*door, *int]{
+			{State: "Device", Parallel: true},
+			{State: "A", Parent: "Device", Events: []E{{Event: "X", Func: retCode, CandList: []string{"B"}}}},
+			{State: "B", Parent: "Device", Events: []E{{Event: "X", Func: retCode, CandList: []string{"A"}}}},
+		},
+	})
+	var h *InvalidHierarchy
+	if !errors.As(err, &h) {
+		t.Fatalf("error %T %v, expected *InvalidHierarchy", err, err)
+	}
+}
@@ -0,0 +1,123 @@
+package fsm
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Time returns the time event occurs
+func (log *TrnasitLog) Time() time.Time { return log.time }
+
+// State returns the State of the transition
+func (log *TrnasitLog) State() string { return log.state }
+
+// Event returns the Event of the transition
+func (log *TrnasitLog) Event() string { return log.event }
+
+// Handle returns the name of Func
+func (log *TrnasitLog) Handle() string { return log.handle }
+
+// Ret returns Func's return code
+func (log *TrnasitLog) Ret() int { return log.ret }
+
+// Next returns the next State
+func (log *TrnasitLog) Next() string { return log.next }
+
+// Err returns the error of the transition, nil if succeeded
+func (log *TrnasitLog) Err() error { return log.err }
+
+// ExitErr returns the error from OnExit of the State
+func (log *TrnasitLog) ExitErr() error { return log.exit }
+
+// EnterErr returns the error from OnEnter of the next State
+func (log *TrnasitLog) EnterErr() error { return log.enter }
+
+// Deferred reports the event is deferred by the State
+func (log *TrnasitLog) Deferred() bool { return log.deferred }
+
+// Replayed reports the event was deferred, and replayed after the State changed
+func (log *TrnasitLog) Replayed() bool { return log.replayed }
+
+// LogRecords returns the latest n logs, if n > 0
+// otherwise returns all logs
+func (e *Entry[OWNER, USERDATA]) LogRecords(last int) []LogRecord {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	nLogs := len(e.Logs)
+	start := 0
+	if last > 0 && nLogs > last {
+		start += nLogs - last
+	}
+	records := make([]LogRecord, 0, nLogs-start)
+	for i := start; i < nLogs; i++ {
+		records = append(records, e.Logs[i].record())
+	}
+	return records
+}
+
+// WriteLogJSON writes the latest n logs as JSON lines, if n > 0
+// otherwise writes all logs
+func (e *Entry[OWNER, USERDATA]) WriteLogJSON(w io.Writer, last int) error {
+	enc := json.NewEncoder(w)
+	for _, r := range e.LogRecords(last) {
+		if err := enc.Encode(&r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Listener emitting a slog record per transition
+type logListener[OWNER any, USERDATA any] struct {
+	NopListener[OWNER, USERDATA]
+	logger *slog.Logger
+}
+
+// NewLogListener creates the Listener which emits a structured record per transition
+// successful transition is logged at LevelInfo, failed one at LevelError,
+// invalid event at LevelWarn
+func NewLogListener[OWNER any, USERDATA any](h slog.Handler) Listener[OWNER, USERDATA] {
+	return &logListener[OWNER, USERDATA]{logger: slog.New(h)}
+}
+
+func (l *logListener[OWNER, USERDATA]) attrs(info *TransitInfo[OWNER, USERDATA]) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("id", info.ID),
+		slog.String("state", info.State),
+		slog.String("event", info.Event),
+		slog.String("func", info.Handle),
+		slog.Int("ret", int(info.RetCode)),
+		slog.String("next", info.Next),
+		slog.Duration("duration", info.Duration),
+	}
+	if info.Err != nil {
+		attrs = append(attrs, slog.String("err", info.Err.Error()))
+	}
+	return attrs
+}
+
+func (l *logListener[OWNER, USERDATA]) AfterTransit(info *TransitInfo[OWNER, USERDATA]) {
+	level := slog.LevelInfo
+	if info.Err != nil {
+		level = slog.LevelError
+	}
+	l.logger.LogAttrs(info.Context, level, "transit", l.attrs(info)...)
+}
+
+func (l *logListener[OWNER, USERDATA]) InvalidEvent(info *TransitInfo[OWNER, USERDATA]) {
+	l.logger.LogAttrs(info.Context, slog.LevelWarn, "invalid event", l.attrs(info)...)
+}
+
+// AddLogHandler logs every transition of all Entries to h, see NewLogListener()
+// MUST be called before any transition
+func (tbl *Table[OWNER, USERDATA]) AddLogHandler(h slog.Handler) {
+	tbl.AddListener(NewLogListener[OWNER, USERDATA](h))
+}
+
+// AddLogHandler logs every transition of the Entry to h, see NewLogListener()
+func (e *Entry[OWNER, USERDATA]) AddLogHandler(h slog.Handler) {
+	e.AddListener(NewLogListener[OWNER, USERDATA](h))
+}
@@ -0,0 +1,71 @@
+package fsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// decodeLines decodes the JSON lines
+func decodeLines(t *testing.T, b *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	lines := make([]map[string]interface{}, 0)
+	s := bufio.NewScanner(b)
+	for s.Scan() {
+		line := make(map[string]interface{})
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLogHandler(t *testing.T) {
+	var b bytes.Buffer
+	tbl := newTestTable(t, doorDesc())
+	tbl.AddLogHandler(slog.NewJSONHandler(&b, nil))
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	d.entry.TransitWithData("Open", code(0))
+	d.entry.TransitWithData("Close", code(9))
+	d.entry.Transit("Kick")
+
+	records := decodeLines(t, &b)
+	if len(records) != 3 {
+		t.Fatalf("%d records, expected 3", len(records))
+	}
+	expected := []struct{ level, msg, state, next string }{
+		{"INFO", "transit", "Closed", "Opened"},
+		{"ERROR", "transit", "Opened", "Opened"},
+		{"WARN", "invalid event", "Opened", "Opened"},
+	}
+	for i, e := range expected {
+		r := records[i]
+		if r["level"] != e.level || r["msg"] != e.msg || r["state"] != e.state || r["next"] != e.next {
+			t.Fatalf("record %d %v, expected %+v", i, r, e)
+		}
+	}
+	if _, ok := records[1]["err"]; !ok {
+		t.Fatalf("record %v, expected err", records[1])
+	}
+}
+
+func TestWriteLogJSON(t *testing.T) {
+	d := &door{}
+	d.entry = newTestTable(t, doorDesc()).NewEntry(d)
+	d.entry.TransitWithData("Open", code(0))
+	d.entry.TransitWithData("Close", code(0))
+
+	var b bytes.Buffer
+	if err := d.entry.WriteLogJSON(&b, 1); err != nil {
+		t.Fatal(err)
+	}
+	records := decodeLines(t, &b)
+	if len(records) != 1 || records[0]["state"] != "Opened" || records[0]["next"] != "Closed" {
+		t.Fatalf("records %v, expected the last transition", records)
+	}
+}
@@ -0,0 +1,341 @@
+package fsm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// Snapshot encoding version
+const SnapshotVersion = 1
+
+// Codec for the values of Entry.Datas, used by Snapshot and Restore
+type DataCodec interface {
+	Encode(key string, value interface{}) ([]byte, error)
+	Decode(key string, data []byte) (interface{}, error)
+}
+
+// JSONDataCodec encodes Datas values as JSON, the default DataCodec
+// decoded values are generic JSON values, e.g. map[string]interface{} and float64,
+// use own DataCodec to restore the original types
+type JSONDataCodec struct{}
+
+func (JSONDataCodec) Encode(key string, value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONDataCodec) Decode(key string, data []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Transition log record of Snapshot
+type LogRecord struct {
+	Time     time.Time `json:"time"`
+	State    string    `json:"state"`
+	Event    string    `json:"event"`
+	Handle   string    `json:"handle"`
+	Ret      int       `json:"ret"`
+	Next     string    `json:"next"`
+	Err      string    `json:"err,omitempty"`
+	ExitErr  string    `json:"exitErr,omitempty"`
+	EnterErr string    `json:"enterErr,omitempty"`
+	Deferred bool      `json:"deferred,omitempty"`
+	Replayed bool      `json:"replayed,omitempty"`
+}
+
+// Snapshot of the Entry, see Entry.Snapshot() and Table.RestoreEntry()
+// Datas values are encoded by the DataCodec of the Table
+type Snapshot struct {
+	Version int               `json:"version"`
+	State   string            `json:"state"`
+	Active  []string          `json:"active,omitempty"`  // active leaf States, if in parallel States
+	History map[string]string `json:"history,omitempty"` // last State of each State Group
+	Datas   map[string][]byte `json:"datas,omitempty"`
+	Logs    []LogRecord       `json:"logs,omitempty"`
+}
+
+// Snapshot Version Error
+type SnapshotVersionError struct {
+	Version int
+	Err     error
+}
+
+func (e *SnapshotVersionError) Error() string {
+	return e.Err.Error() + ": Version=" + strconv.Itoa(e.Version)
+}
+
+func (e *SnapshotVersionError) Unwrap() error { return e.Err }
+
+// Snapshot Data Error, DataCodec failed
+type SnapshotDataError struct {
+	Key string
+	Err error
+}
+
+func (e *SnapshotDataError) Error() string {
+	return e.Err.Error() + ": Key=" + e.Key
+}
+
+func (e *SnapshotDataError) Unwrap() error { return e.Err }
+
+// Invalid State Error
+type InvalidState struct {
+	State string
+	Err   error
+}
+
+func (e *InvalidState) Error() string {
+	return e.Err.Error() + ": State=" + e.State
+}
+
+func (e *InvalidState) Unwrap() error { return e.Err }
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func stringErr(s string) error {
+	if s == "" {
+		return nil
+	}
+	return errors.New(s)
+}
+
+// record converts the log to LogRecord
+func (log *TrnasitLog) record() LogRecord {
+	return LogRecord{
+		Time:     log.time,
+		State:    log.state,
+		Event:    log.event,
+		Handle:   log.handle,
+		Ret:      log.ret,
+		Next:     log.next,
+		Err:      errString(log.err),
+		ExitErr:  errString(log.exit),
+		EnterErr: errString(log.enter),
+		Deferred: log.deferred,
+		Replayed: log.replayed,
+	}
+}
+
+// newTransitLog converts the LogRecord to log,
+// errors are restored as plain errors with the same message
+func newTransitLog(r *LogRecord) *TrnasitLog {
+	return &TrnasitLog{
+		time:   r.Time,
+		state:  r.State,
+		event:  r.Event,
+		handle: r.Handle,
+		ret:    r.Ret,
+		next:   r.Next,
+		err:    stringErr(r.Err),
+		exit:   stringErr(r.ExitErr),
+		enter:  stringErr(r.EnterErr),
+
+		deferred: r.Deferred,
+		replayed: r.Replayed,
+	}
+}
+
+// Snapshot captures State, Datas and Logs of the Entry
+// the deferred events are not captured
+func (e *Entry[OWNER, USERDATA]) Snapshot() (*Snapshot, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.snapshot()
+}
+
+// snapshot captures the Entry, e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) snapshot() (*Snapshot, error) {
+	snap := &Snapshot{
+		Version: SnapshotVersion,
+		State:   e.State.Name,
+		Datas:   make(map[string][]byte, len(e.Datas)),
+		Logs:    make([]LogRecord, 0, len(e.Logs)),
+	}
+	for name, state := range e.history {
+		if snap.History == nil {
+			snap.History = make(map[string]string, len(e.history))
+		}
+		snap.History[name] = state.Name
+	}
+	if len(e.active) > 1 {
+		for _, state := range e.active {
+			snap.Active = append(snap.Active, state.Name)
+		}
+	}
+
+	codec := e.table.codec()
+	for k, v := range e.Datas {
+		data, err := codec.Encode(k, v)
+		if err != nil {
+			return nil, &SnapshotDataError{Key: k, Err: err}
+		}
+		snap.Datas[k] = data
+	}
+	for _, log := range e.Logs {
+		snap.Logs = append(snap.Logs, log.record())
+	}
+
+	return snap, nil
+}
+
+// Restore replaces State, Datas and Logs of the Entry with the snapshot
+// the timeout of the restored State starts again, the deferred events are dropped
+// the Entry of the Manager restored into the final States is removed, see Manager
+// the Entry of NewSyncEntry() waits for the running transition,
+// thus HandleFunc MUST NOT call Restore() of the same Entry, it blocks forever
+func (e *Entry[OWNER, USERDATA]) Restore(snap *Snapshot) error {
+	if err := checkSnapshotVersion(snap.Version); err != nil {
+		return err
+	}
+	if e.serial != nil {
+		e.serial.Lock()
+		defer e.serial.Unlock()
+	}
+	if _, ok := e.table.States[State{snap.State}]; !ok && snap.State != e.table.InitState.Name {
+		return &InvalidState{State: snap.State, Err: fsmerror.ErrInvalidState}
+	}
+	active := e.table.leaves(State{snap.State})
+	if len(snap.Active) > 0 {
+		active = make([]State, 0, len(snap.Active))
+		for _, name := range snap.Active {
+			if _, ok := e.table.States[State{name}]; !ok {
+				return &InvalidState{State: name, Err: fsmerror.ErrInvalidState}
+			}
+			active = append(active, State{name})
+		}
+		if e.table.container(active).Name != snap.State {
+			return &InvalidState{State: snap.State, Err: fsmerror.ErrInvalidState}
+		}
+	}
+
+	history := make(map[string]State, len(snap.History))
+	for name, state := range snap.History {
+		if _, ok := e.table.Groups[name]; !ok {
+			// the group is removed from the Table
+			continue
+		}
+		if _, ok := e.table.States[State{state}]; !ok {
+			return &InvalidState{State: state, Err: fsmerror.ErrInvalidState}
+		}
+		history[name] = State{state}
+	}
+
+	codec := e.table.codec()
+	datas := make(map[string]interface{}, len(snap.Datas))
+	for k, data := range snap.Datas {
+		v, err := codec.Decode(k, data)
+		if err != nil {
+			return &SnapshotDataError{Key: k, Err: err}
+		}
+		datas[k] = v
+	}
+
+	logs := make([]*TrnasitLog, 0, len(snap.Logs))
+	start := 0
+	if e.LogMax > 0 && len(snap.Logs) > e.LogMax {
+		start = len(snap.Logs) - e.LogMax
+	}
+	if e.LogMax > 0 {
+		for i := start; i < len(snap.Logs); i++ {
+			logs = append(logs, newTransitLog(&snap.Logs[i]))
+		}
+	}
+
+	e.mu.Lock()
+	e.State = e.table.container(active)
+	e.active = active
+	e.history = history
+	e.remember()
+	e.Datas = datas
+	e.Logs = logs
+	// the events deferred by the replaced State
+	e.deferred = nil
+	e.armTimer(e.State)
+	listeners := e.listeners
+	e.mu.Unlock()
+
+	// the managed Entry restored into the final States leaves the Manager
+	for _, l := range listeners {
+		if r, ok := l.(restoreListener); ok {
+			r.afterRestore()
+		}
+	}
+	return nil
+}
+
+// RestoreEntry creates New FSM Entry Instance from the snapshot
+// owner Entry Owner
+func (tbl *Table[OWNER, USERDATA]) RestoreEntry(owner OWNER, snap *Snapshot) (*Entry[OWNER, USERDATA], error) {
+	entry := tbl.NewEntry(owner)
+	if err := entry.Restore(snap); err != nil {
+		entry.StopTimer()
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (tbl *Table[OWNER, USERDATA]) codec() DataCodec {
+	if tbl.DataCodec == nil {
+		return JSONDataCodec{}
+	}
+	return tbl.DataCodec
+}
+
+func checkSnapshotVersion(version int) error {
+	if version <= 0 || version > SnapshotVersion {
+		return &SnapshotVersionError{Version: version, Err: fsmerror.ErrSnapshotVersion}
+	}
+	return nil
+}
+
+// EncodeJSON encodes the snapshot as JSON
+func (snap *Snapshot) EncodeJSON() ([]byte, error) {
+	return json.Marshal(snap)
+}
+
+// EncodeGob encodes the snapshot as gob
+func (snap *Snapshot) EncodeGob() ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(snap); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// DecodeSnapshotJSON decodes the snapshot encoded by EncodeJSON()
+func DecodeSnapshotJSON(data []byte) (*Snapshot, error) {
+	snap := &Snapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, err
+	}
+	if err := checkSnapshotVersion(snap.Version); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// DecodeSnapshotGob decodes the snapshot encoded by EncodeGob()
+func DecodeSnapshotGob(data []byte) (*Snapshot, error) {
+	snap := &Snapshot{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(snap); err != nil {
+		return nil, err
+	}
+	if err := checkSnapshotVersion(snap.Version); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
@@ -0,0 +1,128 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	tbl := newTestTable(t, doorDesc())
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	d.entry.Transit("Open")
+	d.entry.Set("visits", 3)
+
+	snap, err := d.entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, codec := range map[string]struct {
+		encode func() ([]byte, error)
+		decode func([]byte) (*Snapshot, error)
+	}{
+		"json": {snap.EncodeJSON, DecodeSnapshotJSON},
+		"gob":  {snap.EncodeGob, DecodeSnapshotGob},
+	} {
+		data, err := codec.encode()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		decoded, err := codec.decode(data)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		restored, err := tbl.RestoreEntry(&door{}, decoded)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		expectState(t, restored, "Opened")
+		// JSONDataCodec restores numbers as float64
+		if v := restored.Get("visits"); v != float64(3) {
+			t.Fatalf("%s: visits=%v, expected 3", name, v)
+		}
+		if logs := restored.LogRecords(0); len(logs) != 1 || logs[0].Next != "Opened" {
+			t.Fatalf("%s: logs %+v, expected Closed to Opened", name, logs)
+		}
+		// the restored Entry goes on from the State
+		if _, _, err := restored.Transit("Close"); err != nil {
+			t.Fatal(name, err)
+		}
+		expectState(t, restored, "Closed")
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	tbl := newTestTable(t, doorDesc())
+
+	var verr *SnapshotVersionError
+	if _, err := tbl.RestoreEntry(&door{}, &Snapshot{Version: SnapshotVersion + 1, State: "Closed"}); !errors.As(err, &verr) {
+		t.Fatalf("error %v, expected *SnapshotVersionError", err)
+	}
+	var serr *InvalidState
+	if _, err := tbl.RestoreEntry(&door{}, &Snapshot{Version: SnapshotVersion, State: "Broken"}); !errors.As(err, &serr) || serr.State != "Broken" {
+		t.Fatalf("error %v, expected *InvalidState", err)
+	}
+	if _, err := DecodeSnapshotJSON([]byte(`{"version": 0, "state": "Closed"}`)); !errors.As(err, &verr) {
+		t.Fatalf("error %v, expected *SnapshotVersionError", err)
+	}
+}
+
+func TestRestoreDropsDeferred(t *testing.T) {
+	d := &door{}
+	d.entry = openingTable(t).NewEntry(d)
+	snap, err := d.entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.entry.Transit("Open")
+	d.entry.Transit("Lock")
+	if d.entry.Deferred() != 1 {
+		t.Fatalf("%d deferred, expected Lock held in Opening", d.entry.Deferred())
+	}
+
+	if err := d.entry.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if d.entry.Deferred() != 0 {
+		t.Fatalf("%d deferred after restore, expected 0", d.entry.Deferred())
+	}
+	// the Lock held before the restore is not replayed
+	d.entry.Transit("Open")
+	d.entry.Transit("Done")
+	expectState(t, d.entry, "Opened")
+}
+
+func TestRestoreWaitsTransit(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	desc := doorDesc()
+	desc.States[0].Events[0].Func = func(d *door, ev Event, n *int) (HandleRetCode, error) {
+		close(entered)
+		<-release
+		return ExitOK, nil
+	}
+	d := &door{}
+	d.entry = newTestTable(t, desc).NewSyncEntry(d)
+	snap, err := d.entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go d.entry.Transit("Open")
+	<-entered
+	restored := make(chan error)
+	go func() { restored <- d.entry.Restore(snap) }()
+	select {
+	case err := <-restored:
+		t.Fatalf("restored during the transition, error %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the restore runs after the transition, and wins
+	close(release)
+	if err := <-restored; err != nil {
+		t.Fatal(err)
+	}
+	expectState(t, d.entry, "Closed")
+}
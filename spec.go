@@ -0,0 +1,482 @@
+package fsm
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// Named Handler Registry, used to resolve the Func of a specification file
+//
+//	reg := fsm.HandlerRegistry[*Door, *Key]{
+//	    "OpenDoor": OpenDoor,
+//	    "LockDoor": LockDoor,
+//	}
+type HandlerRegistry[OWNER any, USERDATA any] map[string]HandleFuncv2[OWNER, USERDATA]
+
+// FSM Event Action Specification, the file form of EventDesc
+// Func is the name of the handler in the HandlerRegistry
+// CandMap keys are decimal return codes, e.g. {"0": "Locked", "100": "Retry"}
+type EventSpec struct {
+	Event    string            `json:"event" yaml:"event"`
+	Func     string            `json:"func" yaml:"func"`
+	CandList []string          `json:"candList,omitempty" yaml:"candList,omitempty"`
+	CandMap  map[string]string `json:"candMap,omitempty" yaml:"candMap,omitempty"`
+
+	line    int            // line of this event
+	codeLns map[string]int // line of each CandMap key
+}
+
+// FSM State Specification, the file form of StateDesc
+type StateSpec struct {
+	State        string        `json:"state" yaml:"state"`
+	Parent       string        `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Initial      string        `json:"initial,omitempty" yaml:"initial,omitempty"`
+	Parallel     bool          `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	Events       []EventSpec   `json:"events,omitempty" yaml:"events,omitempty"`
+	Timeout      time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	TimeoutEvent string        `json:"timeoutEvent,omitempty" yaml:"timeoutEvent,omitempty"`
+	Defer        []string      `json:"defer,omitempty" yaml:"defer,omitempty"`
+
+	line int // line of this state
+}
+
+// FSM State Group Specification, the file form of GroupDesc
+// the history target is written as "history:<name>" in candList and candMap
+type GroupSpec struct {
+	Name    string   `json:"name" yaml:"name"`
+	States  []string `json:"states" yaml:"states"`
+	Default string   `json:"default" yaml:"default"`
+
+	line int // line of this group
+}
+
+// FSM State-Event Table Specification, the file form of TableDesc
+// JSON is a subset of YAML, so both are read by the same parser
+//
+//	initState: Closed
+//	finalStates: [Closed, Locked]
+//	logMax: 20
+//	states:
+//	  - state: Closed
+//	    events:
+//	      - event: Lock
+//	        func: LockDoor
+//	        candList: [Locked, Closed]
+type TableSpec struct {
+	InitState   string      `json:"initState" yaml:"initState"`
+	FinalStates []string    `json:"finalStates,omitempty" yaml:"finalStates,omitempty"`
+	LogMax      int         `json:"logMax,omitempty" yaml:"logMax,omitempty"`
+	Strict      bool        `json:"strict,omitempty" yaml:"strict,omitempty"`
+	DeferMax    int         `json:"deferMax,omitempty" yaml:"deferMax,omitempty"`
+	PanicPolicy PanicPolicy `json:"panicPolicy,omitempty" yaml:"panicPolicy,omitempty"`
+	PanicState  string      `json:"panicState,omitempty" yaml:"panicState,omitempty"`
+	States      []StateSpec `json:"states" yaml:"states"`
+	Groups      []GroupSpec `json:"groups,omitempty" yaml:"groups,omitempty"`
+
+	File string `json:"-" yaml:"-"` // source file name, for error report
+	line int    // line of the document
+}
+
+// Specification File Error
+// Err is the underlying error, typically one of the typed table errors
+// such as *UndefinedHandle or *HandleRetCodeRangeError
+type SpecError struct {
+	File string // specification file name
+	Line int    // line number, 0 if unknown
+	Err  error
+}
+
+func (e *SpecError) Error() string {
+	return e.Err.Error() + ": File=" + e.File + ", Line=" + strconv.Itoa(e.Line)
+}
+
+func (e *SpecError) Unwrap() error { return e.Err }
+
+// Invalid Specification Error, for syntax and type errors of the file
+type InvalidSpec struct {
+	Reason string
+	Err    error
+}
+
+func (e *InvalidSpec) Error() string {
+	return e.Err.Error() + ": " + e.Reason
+}
+
+func (e *InvalidSpec) Unwrap() error { return e.Err }
+
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+// specError annotates yaml parser errors with file and line
+func specError(file string, err error) error {
+	line := 0
+	if m := yamlLineRe.FindStringSubmatch(err.Error()); m != nil {
+		line, _ = strconv.Atoi(m[1])
+	}
+	return &SpecError{
+		File: file,
+		Line: line,
+		Err:  &InvalidSpec{Reason: err.Error(), Err: fsmerror.ErrInvalidSpec},
+	}
+}
+
+// ParseTableSpec parses YAML or JSON specification
+// file name to be reported in errors
+func ParseTableSpec(data []byte, file string) (*TableSpec, error) {
+	spec := &TableSpec{File: file}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(spec); err != nil {
+		return nil, specError(file, err)
+	}
+
+	// decode again to find out the line of each item
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, specError(file, err)
+	}
+	if len(doc.Content) > 0 {
+		spec.setLines(doc.Content[0])
+	}
+
+	return spec, nil
+}
+
+// ReadTableSpec reads YAML or JSON specification file
+// the read error is returned as *SpecError of Line 0, wrapping the error of os.ReadFile
+func ReadTableSpec(path string) (*TableSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &SpecError{File: path, Err: err}
+	}
+	return ParseTableSpec(data, path)
+}
+
+// mapValue returns the value node of key in mapping node n
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func (spec *TableSpec) setLines(root *yaml.Node) {
+	spec.line = root.Line
+	if groups := mapValue(root, "groups"); groups != nil {
+		for i := range spec.Groups {
+			if i < len(groups.Content) {
+				spec.Groups[i].line = groups.Content[i].Line
+			}
+		}
+	}
+	states := mapValue(root, "states")
+	if states == nil {
+		return
+	}
+	for i := range spec.States {
+		if i >= len(states.Content) {
+			break
+		}
+		sn := states.Content[i]
+		state := &spec.States[i]
+		state.line = sn.Line
+
+		events := mapValue(sn, "events")
+		if events == nil {
+			continue
+		}
+		for j := range state.Events {
+			if j >= len(events.Content) {
+				break
+			}
+			en := events.Content[j]
+			event := &state.Events[j]
+			event.line = en.Line
+
+			if cm := mapValue(en, "candMap"); cm != nil {
+				event.codeLns = make(map[string]int)
+				for k := 0; k+1 < len(cm.Content); k += 2 {
+					event.codeLns[cm.Content[k].Value] = cm.Content[k].Line
+				}
+			}
+		}
+	}
+}
+
+// Line returns the line number of the {state, event} in the specification
+// event may be empty or "any", then the line of the state is returned
+// if the state is not declared, the line which refers it as next state is returned
+func (spec *TableSpec) Line(state, event string) int {
+	line := 0
+	for _, s := range spec.States {
+		if s.State != state {
+			continue
+		}
+		if line == 0 {
+			line = s.line
+		}
+		for _, e := range s.Events {
+			if e.Event == event {
+				return e.line
+			}
+		}
+	}
+	if line != 0 {
+		return line
+	}
+
+	// not declared, the first event which refers the state as next state
+	for _, s := range spec.States {
+		for _, e := range s.Events {
+			if event != "" && event != "any" && e.Event != event {
+				continue
+			}
+			for _, nstate := range e.CandList {
+				if nstate == state {
+					return e.line
+				}
+			}
+			// the first code in declaration order, not in random map order
+			first := 0
+			for code, nstate := range e.CandMap {
+				if ln := e.codeLns[code]; nstate == state && (first == 0 || ln < first) {
+					first = ln
+				}
+			}
+			if first != 0 {
+				return first
+			}
+		}
+	}
+	return spec.line
+}
+
+// redeclared returns the line of the second {state, event} in the specification,
+// which conflicts with the first one, or Line() if not redeclared
+func (spec *TableSpec) redeclared(state, event string) int {
+	found := false
+	for _, s := range spec.States {
+		if s.State != state {
+			continue
+		}
+		for _, e := range s.Events {
+			if e.Event != event {
+				continue
+			}
+			if found {
+				return e.line
+			}
+			found = true
+		}
+	}
+	return spec.Line(state, event)
+}
+
+// Handlers returns the handler names referenced by the specification,
+// in order of appearance, without duplicates
+func (spec *TableSpec) Handlers() []string {
+	names := make([]string, 0)
+	seen := make(map[string]interface{})
+	for _, s := range spec.States {
+		for _, e := range s.Events {
+			if _, ok := seen[e.Func]; ok || e.Func == "" {
+				continue
+			}
+			seen[e.Func] = nil
+			names = append(names, e.Func)
+		}
+	}
+	return names
+}
+
+// annotate attaches the file and line of the {state, event} the error refers to
+func (spec *TableSpec) annotate(err error) error {
+	var (
+		conflict *StateEventConflictError
+		rangeErr *HandleRetCodeRangeError
+		dupErr   *HandleRetCodeDupError
+		emptyErr *HandleEmptyRetCodeError
+		undefH   *UndefinedHandle
+		undefR   *UndefinedRetCode
+		hier     *InvalidHierarchy
+		group    *InvalidGroup
+		undefG   *UndefinedGroup
+		wildcard *AmbiguousWildcard
+		line     int
+	)
+	switch {
+	case errors.As(err, &conflict):
+		line = spec.redeclared(conflict.State, conflict.Event)
+	case errors.As(err, &rangeErr):
+		line = spec.Line(rangeErr.State, rangeErr.Event)
+	case errors.As(err, &dupErr):
+		line = spec.Line(dupErr.State, dupErr.Event)
+	case errors.As(err, &emptyErr):
+		line = spec.Line(emptyErr.State, emptyErr.Event)
+	case errors.As(err, &undefH):
+		line = spec.Line(undefH.State, undefH.Event)
+	case errors.As(err, &undefR):
+		line = spec.Line(undefR.State, undefR.Event)
+	case errors.As(err, &hier):
+		line = spec.Line(hier.State, "")
+	case errors.As(err, &group):
+		line = spec.line
+		for _, g := range spec.Groups {
+			if g.Name == group.Group {
+				line = g.line
+			}
+		}
+	case errors.As(err, &undefG):
+		line = spec.Line(undefG.State, undefG.Event)
+	case errors.As(err, &wildcard):
+		line = spec.Line(wildcard.State, wildcard.Event)
+	}
+	return &SpecError{File: spec.File, Line: line, Err: err}
+}
+
+// NewTableDesc builds the TableDesc from the specification
+// Func names are resolved by reg
+func NewTableDesc[OWNER any, USERDATA any](spec *TableSpec, reg HandlerRegistry[OWNER, USERDATA]) (*TableDesc[OWNER, USERDATA], error) {
+	d := &TableDesc[OWNER, USERDATA]{
+		InitState:   spec.InitState,
+		FinalStates: spec.FinalStates,
+		LogMax:      spec.LogMax,
+		Strict:      spec.Strict,
+		DeferMax:    spec.DeferMax,
+		PanicPolicy: spec.PanicPolicy,
+		PanicState:  spec.PanicState,
+		States:      make([]StateDesc[OWNER, USERDATA], 0, len(spec.States)),
+	}
+	for _, g := range spec.Groups {
+		d.Groups = append(d.Groups, GroupDesc{Name: g.Name, States: g.States, Default: g.Default})
+	}
+
+	for _, s := range spec.States {
+		state := StateDesc[OWNER, USERDATA]{
+			State:        s.State,
+			Parent:       s.Parent,
+			Initial:      s.Initial,
+			Parallel:     s.Parallel,
+			Events:       make([]EventDesc[OWNER, USERDATA], 0, len(s.Events)),
+			Timeout:      s.Timeout,
+			TimeoutEvent: s.TimeoutEvent,
+			Defer:        s.Defer,
+		}
+		for _, e := range s.Events {
+			f, ok := reg[e.Func]
+			if !ok || f == nil {
+				return nil, &SpecError{
+					File: spec.File,
+					Line: e.line,
+					Err: &UndefinedHandle{
+						State: s.State,
+						Event: e.Event,
+						Err:   fsmerror.ErrHandleNotExists,
+					},
+				}
+			}
+
+			if len(e.CandList) > ExitEnd+1 {
+				return nil, &SpecError{
+					File: spec.File,
+					Line: e.line,
+					Err: &HandleRetCodeRangeError{
+						State:  s.State,
+						Event:  e.Event,
+						Handle: e.Func,
+						Code:   HandleRetCode(len(e.CandList) - 1),
+						Err:    fsmerror.ErrInvalidRetCode,
+					},
+				}
+			}
+
+			var cmap CandMap
+			if e.CandMap != nil {
+				cmap = make(CandMap, len(e.CandMap))
+				for k, nstate := range e.CandMap {
+					code, err := strconv.Atoi(k)
+					if err != nil {
+						return nil, &SpecError{
+							File: spec.File,
+							Line: e.codeLns[k],
+							Err: &InvalidSpec{
+								Reason: "return code " + strconv.Quote(k) + " is not an integer",
+								Err:    fsmerror.ErrInvalidRetCode,
+							},
+						}
+					}
+					if _, dup := cmap[HandleRetCode(code)]; dup || code >= 0 && code < len(e.CandList) {
+						return nil, &SpecError{
+							File: spec.File,
+							Line: e.codeLns[k],
+							Err: &HandleRetCodeDupError{
+								State:  s.State,
+								Event:  e.Event,
+								Handle: e.Func,
+								Code:   HandleRetCode(code),
+								Err:    fsmerror.ErrDupRetCode,
+							},
+						}
+					}
+					cmap[HandleRetCode(code)] = nstate
+				}
+			}
+
+			state.Events = append(state.Events, EventDesc[OWNER, USERDATA]{
+				Event:    e.Event,
+				Name:     e.Func,
+				Func:     f,
+				CandMap:  cmap,
+				CandList: e.CandList,
+			})
+		}
+		d.States = append(d.States, state)
+	}
+
+	return d, nil
+}
+
+// LoadTableDesc reads the specification file and builds the TableDesc
+func LoadTableDesc[OWNER any, USERDATA any](path string, reg HandlerRegistry[OWNER, USERDATA]) (*TableDesc[OWNER, USERDATA], error) {
+	spec, err := ReadTableSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewTableDesc(spec, reg)
+}
+
+// LoadTable reads the specification file and creates the Table
+// errors from NewTable are annotated with file and line of the {State, Event}
+func LoadTable[OWNER any, USERDATA any](path string, reg HandlerRegistry[OWNER, USERDATA], opts ...Opts) (*Table[OWNER, USERDATA], error) {
+	spec, err := ReadTableSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewTableFromSpec(spec, reg, opts...)
+}
+
+// NewTableFromSpec creates the Table from the parsed specification
+// errors from NewTable are annotated with file and line of the {State, Event}
+func NewTableFromSpec[OWNER any, USERDATA any](spec *TableSpec, reg HandlerRegistry[OWNER, USERDATA], opts ...Opts) (*Table[OWNER, USERDATA], error) {
+	d, err := NewTableDesc(spec, reg)
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := NewTable(d, opts...)
+	if err != nil {
+		return tbl, spec.annotate(err)
+	}
+	return tbl, nil
+}
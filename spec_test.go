@@ -0,0 +1,198 @@
+package fsm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// doorSpec is the door of doorDesc, the lines are referred by the tests
+const doorSpec = `initState: Closed
+finalStates: [Locked]
+states:
+  - state: Closed
+    events:
+      - event: Open
+        func: Open
+        candList: [Opened, Closed]
+      - event: Close
+        func: Close
+        candList: [Closed]
+      - event: Lock
+        func: Lock
+        candList: [Locked, Closed]
+  - state: Opened
+    events:
+      - event: Open
+        func: Open
+        candList: [Opened]
+      - event: Close
+        func: Close
+        candList: [Closed, Opened]
+`
+
+// doorReg resolves the handlers of doorSpec
+func doorReg() HandlerRegistry[*door, *int] {
+	return HandlerRegistry[*door, *int]{"Open": retCode, "Close": retCode, "Lock": retCode}
+}
+
+// loadSpec parses the specification and creates the Table
+func loadSpec(t *testing.T, data string, reg HandlerRegistry[*door, *int]) (*Table[*door, *int], error) {
+	t.Helper()
+	spec, err := ParseTableSpec([]byte(data), "door.yaml")
+	if err != nil {
+		return nil, err
+	}
+	return NewTableFromSpec(spec, reg)
+}
+
+// expectSpecError fails the test if err is not *SpecError at the line
+func expectSpecError(t *testing.T, err error, line int) *SpecError {
+	t.Helper()
+	var specErr *SpecError
+	if !errors.As(err, &specErr) {
+		t.Fatalf("expected *SpecError, got %v", err)
+	}
+	if specErr.File != "door.yaml" || specErr.Line != line {
+		t.Fatalf("File=%s, Line=%d, expected door.yaml:%d: %v", specErr.File, specErr.Line, line, err)
+	}
+	return specErr
+}
+
+func TestLoadSpec(t *testing.T) {
+	tbl, err := loadSpec(t, doorSpec, doorReg())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	if _, _, err := d.entry.TransitWithData("Open", nil); err != nil {
+		t.Fatal(err)
+	}
+	expectState(t, d.entry, "Opened")
+
+	path := filepath.Join(t.TempDir(), "door.yaml")
+	if err := os.WriteFile(path, []byte(doorSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTable(path, doorReg()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadSpecNotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "none.yaml")
+	_, err := ReadTableSpec(path)
+	var specErr *SpecError
+	if !errors.As(err, &specErr) || specErr.File != path || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSpecUndefinedHandler(t *testing.T) {
+	reg := doorReg()
+	delete(reg, "Lock")
+	_, err := loadSpec(t, doorSpec, reg)
+	expectSpecError(t, err, 12)
+	var undefH *UndefinedHandle
+	if !errors.As(err, &undefH) || undefH.State != "Closed" || undefH.Event != "Lock" {
+		t.Fatalf("expected *UndefinedHandle, got %v", err)
+	}
+}
+
+func TestSpecRetCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		events string
+		line   int
+		check  func(err error) bool
+	}{
+		{"not integer", `      - event: Open
+        func: Open
+        candMap:
+          zero: Opened
+`, 8, func(err error) bool {
+			var invalid *InvalidSpec
+			return errors.As(err, &invalid) && errors.Is(err, fsmerror.ErrInvalidRetCode)
+		}},
+		{"duplicated", `      - event: Open
+        func: Open
+        candList: [Opened]
+        candMap:
+          0: Closed
+`, 9, func(err error) bool {
+			var dupErr *HandleRetCodeDupError
+			return errors.As(err, &dupErr) && dupErr.Code == 0
+		}},
+		{"out of range", `      - event: Open
+        func: Open
+        candList: [` + strings.Repeat("Opened, ", ExitEnd+1) + `Opened]
+`, 5, func(err error) bool {
+			var rangeErr *HandleRetCodeRangeError
+			return errors.As(err, &rangeErr)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := "initState: Opened\nstates:\n  - state: Opened\n    events:\n" + tt.events +
+				"  - state: Closed\n    events:\n      - event: Open\n        func: Open\n        candList: [Opened]\n"
+			_, err := loadSpec(t, spec, doorReg())
+			expectSpecError(t, err, tt.line)
+			if !tt.check(err) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestSpecUnknownField(t *testing.T) {
+	spec := strings.Replace(doorSpec, "candList: [Closed]", "candlist: [Closed]", 1)
+	_, err := loadSpec(t, spec, doorReg())
+	specErr := expectSpecError(t, err, 11)
+	var invalid *InvalidSpec
+	if !errors.As(specErr, &invalid) || !errors.Is(err, fsmerror.ErrInvalidSpec) {
+		t.Fatalf("expected *InvalidSpec, got %v", err)
+	}
+}
+
+func TestSpecConflictLine(t *testing.T) {
+	// the second Close of Opened conflicts with the first one
+	spec := doorSpec + `      - event: Close
+        func: Open
+        candList: [Closed]
+`
+	_, err := loadSpec(t, spec, doorReg())
+	expectSpecError(t, err, 23)
+	var conflict *StateEventConflictError
+	if !errors.As(err, &conflict) || conflict.State != "Opened" || conflict.Event != "Close" {
+		t.Fatalf("expected *StateEventConflictError, got %v", err)
+	}
+}
+
+func TestSpecLineStable(t *testing.T) {
+	// Broken is not declared, the line of its first code is reported
+	spec, err := ParseTableSpec([]byte(`initState: A
+states:
+  - state: A
+    events:
+      - event: T
+        func: T
+        candMap:
+          1: Broken
+          2: Broken
+          3: Broken
+          4: Broken
+`), "door.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if line := spec.Line("Broken", "T"); line != 8 {
+			t.Fatalf("line %d, expected 8", line)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package fsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// Persistent Store of Entry Snapshots, indexed by Entry ID
+// the Entry saves its snapshot after every committed transition, see Entry.SetStore()
+type Store interface {
+	// Load returns the snapshot, *EntryNotFound if not exists
+	Load(id string) (*Snapshot, error)
+	Save(id string, snap *Snapshot) error
+	Delete(id string) error
+}
+
+// Entry Not Found Error
+type EntryNotFound struct {
+	ID  string
+	Err error
+}
+
+func (e *EntryNotFound) Error() string {
+	return e.Err.Error() + ": ID=" + e.ID
+}
+
+func (e *EntryNotFound) Unwrap() error { return e.Err }
+
+// Persist Error, the transition is rolled back
+type PersistError struct {
+	ID    string // Entry ID
+	State string // State rolled back to
+	Event string // Event of the transition
+	Cause error  // Error, from Store
+	Err   error
+}
+
+func (e *PersistError) Error() string {
+	return e.Err.Error() + ": ID=" + e.ID + ", State=" + e.State +
+		", Event=" + e.Event + ", Cause=" + e.Cause.Error()
+}
+
+func (e *PersistError) Unwrap() error { return e.Err }
+
+// SetStore makes the Entry persistent, saved to store as id
+// after every transition which resolved the next state
+// if the store fails, the transition is rolled back and *PersistError is returned,
+// side effects of Func, OnExit and OnEnter are not rolled back
+func (e *Entry[OWNER, USERDATA]) SetStore(id string, store Store) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.id = id
+	e.store = store
+}
+
+// ID returns the Entry ID given by SetStore()
+func (e *Entry[OWNER, USERDATA]) ID() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.id
+}
+
+// LoadEntry restores the Entry saved as id in store,
+// the Entry keeps saving to store
+// owner Entry Owner
+func (tbl *Table[OWNER, USERDATA]) LoadEntry(owner OWNER, id string, store Store) (*Entry[OWNER, USERDATA], error) {
+	snap, err := store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := tbl.RestoreEntry(owner, snap)
+	if err != nil {
+		return nil, err
+	}
+	entry.SetStore(id, store)
+	return entry, nil
+}
+
+// Entry state to roll back
+type entryState[USERDATA any] struct {
+	state    State
+	active   []State
+	history  map[string]State
+	deferred []deferredEvent[USERDATA]
+	datas    map[string]interface{}
+	logs     []*TrnasitLog
+}
+
+// keep copies the Entry state, e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) keep() *entryState[USERDATA] {
+	saved := &entryState[USERDATA]{
+		state:    e.State,
+		active:   e.active,
+		history:  make(map[string]State, len(e.history)),
+		deferred: append([]deferredEvent[USERDATA]{}, e.deferred...),
+		datas:    make(map[string]interface{}, len(e.Datas)),
+		logs:     make([]*TrnasitLog, len(e.Logs)),
+	}
+	for k, v := range e.Datas {
+		saved.datas[k] = v
+	}
+	copy(saved.logs, e.Logs)
+	for k, v := range e.history {
+		saved.history[k] = v
+	}
+	return saved
+}
+
+// rollback restores the Entry state, e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) rollback(saved *entryState[USERDATA]) {
+	changed := e.State != saved.state
+	e.State = saved.state
+	e.active = saved.active
+	e.history = saved.history
+	e.deferred = saved.deferred
+	e.Datas = saved.datas
+	e.Logs = saved.logs
+	if changed {
+		e.armTimer(e.State)
+	}
+}
+
+// Corrupted Store Error, the record at Offset is broken
+type CorruptedStore struct {
+	Path   string
+	Offset int64
+	Err    error
+}
+
+func (e *CorruptedStore) Error() string {
+	return e.Err.Error() + ": Path=" + e.Path + ", Offset=" + strconv.FormatInt(e.Offset, 10)
+}
+
+func (e *CorruptedStore) Unwrap() error { return e.Err }
+
+// MemStore is the Store on memory
+type MemStore struct {
+	mu    sync.RWMutex
+	snaps map[string][]byte
+}
+
+// NewMemStore creates empty MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{snaps: make(map[string][]byte)}
+}
+
+func (s *MemStore) Load(id string) (*Snapshot, error) {
+	s.mu.RLock()
+	data, ok := s.snaps[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, &EntryNotFound{ID: id, Err: fsmerror.ErrEntryNotFound}
+	}
+	return DecodeSnapshotJSON(data)
+}
+
+func (s *MemStore) Save(id string, snap *Snapshot) error {
+	// keep encoded, the caller may modify snap
+	data, err := snap.EncodeJSON()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snaps[id] = data
+	return nil
+}
+
+func (s *MemStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.snaps, id)
+	return nil
+}
+
+// IDs returns the stored Entry IDs, sorted
+func (s *MemStore) IDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.snaps))
+	for id := range s.snaps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// FileStore is the Store on append-only file
+// every Save and Delete appends one JSON line and syncs the file,
+// OpenFileStore replays the lines and drops the torn last line of the crash
+type FileStore struct {
+	mem  *MemStore
+	mu   sync.Mutex // serializes writes
+	path string
+	file *os.File
+	size int64 // end of the last complete record
+}
+
+// FileStore record, one per line
+type fileRecord struct {
+	Op   string          `json:"op"` // "save" or "delete"
+	ID   string          `json:"id"`
+	Snap json.RawMessage `json:"snap,omitempty"`
+}
+
+// OpenFileStore opens or creates the FileStore file
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{mem: NewMemStore(), path: path, file: file}
+	if err := s.recover(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// recover replays the file, truncates the incomplete last line
+func (s *FileStore) recover() error {
+	r := bufio.NewReader(s.file)
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// no newline, torn write
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rec := fileRecord{}
+		if jerr := json.Unmarshal(bytes.TrimSpace(line), &rec); jerr != nil {
+			if _, perr := r.Peek(1); perr == io.EOF {
+				// broken last line, torn write
+				break
+			}
+			return &CorruptedStore{Path: s.path, Offset: good, Err: fsmerror.ErrStoreCorrupted}
+		}
+		switch rec.Op {
+		case "save":
+			s.mem.snaps[rec.ID] = []byte(rec.Snap)
+		case "delete":
+			delete(s.mem.snaps, rec.ID)
+		}
+		good += int64(len(line))
+	}
+
+	s.size = good
+	return s.truncate()
+}
+
+// truncate drops the data after the last complete record
+func (s *FileStore) truncate() error {
+	if err := s.file.Truncate(s.size); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(s.size, io.SeekStart)
+	return err
+}
+
+// append writes the record, syncs the file, then applies it to the memory
+func (s *FileStore) append(rec *fileRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		// do not leave the partial record
+		s.truncate()
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		s.truncate()
+		return err
+	}
+	s.size += int64(len(line))
+
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	switch rec.Op {
+	case "save":
+		s.mem.snaps[rec.ID] = []byte(rec.Snap)
+	case "delete":
+		delete(s.mem.snaps, rec.ID)
+	}
+	return nil
+}
+
+func (s *FileStore) Load(id string) (*Snapshot, error) {
+	return s.mem.Load(id)
+}
+
+func (s *FileStore) Save(id string, snap *Snapshot) error {
+	data, err := snap.EncodeJSON()
+	if err != nil {
+		return err
+	}
+	return s.append(&fileRecord{Op: "save", ID: id, Snap: data})
+}
+
+func (s *FileStore) Delete(id string) error {
+	return s.append(&fileRecord{Op: "delete", ID: id})
+}
+
+// IDs returns the stored Entry IDs, sorted
+func (s *FileStore) IDs() []string {
+	return s.mem.IDs()
+}
+
+// Compact rewrites the file with the latest snapshot of each Entry
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	// the temporary file is removed on failure only, it is the file after the rename
+	abort := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, id := range s.mem.IDs() {
+		s.mem.mu.RLock()
+		data := s.mem.snaps[id]
+		s.mem.mu.RUnlock()
+		line, err := json.Marshal(&fileRecord{Op: "save", ID: id, Snap: data})
+		if err != nil {
+			return abort(err)
+		}
+		if _, err := w.Write(line); err != nil {
+			return abort(err)
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return abort(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return abort(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return abort(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return abort(err)
+	}
+	if dir, err := os.Open(filepath.Dir(s.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	s.file.Close()
+	s.file = tmp
+	s.size, err = s.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Close closes the file
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,136 @@
+package fsm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.log")
+	tbl := newTestTable(t, doorDesc())
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+	d.entry.SetStore("door-1", store)
+	d.entry.Transit("Open")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the torn write of the crash is dropped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"save","id":"door-2"`)
+	f.Close()
+
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if ids := store.IDs(); len(ids) != 1 || ids[0] != "door-1" {
+		t.Fatalf("ids %v, expected [door-1]", ids)
+	}
+	loaded, err := tbl.LoadEntry(&door{}, "door-1", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectState(t, loaded, "Opened")
+
+	// the loaded Entry keeps saving, Compact keeps the latest snapshot
+	loaded.Transit("Close")
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := store.Load("door-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.State != "Closed" {
+		t.Fatalf("stored state %s, expected Closed", snap.State)
+	}
+
+	var nf *EntryNotFound
+	if _, err := tbl.LoadEntry(&door{}, "door-2", store); !errors.As(err, &nf) {
+		t.Fatalf("error %v, expected *EntryNotFound", err)
+	}
+}
+
+func TestFileStoreCompactFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "entries.log")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	d := &door{}
+	d.entry = newTestTable(t, doorDesc()).NewEntry(d)
+	d.entry.SetStore("door-1", store)
+	d.entry.Transit("Open")
+
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("%d files after Compact, expected the log only", len(files))
+	}
+
+	// the rename fails onto the directory, the temporary file is removed
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Compact(); err == nil {
+		t.Fatal("Compact succeeded, expected the rename failure")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("%d files after failed Compact, expected the temporary file removed", len(files))
+	}
+}
+
+func TestFileStoreCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.log")
+	if err := os.WriteFile(path, []byte("broken\n{\"op\":\"delete\",\"id\":\"a\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cerr *CorruptedStore
+	if _, err := OpenFileStore(path); !errors.As(err, &cerr) || cerr.Offset != 0 {
+		t.Fatalf("error %v, expected *CorruptedStore at 0", err)
+	}
+}
+
+// failStore fails every Save
+type failStore struct {
+	*MemStore
+}
+
+func (s failStore) Save(id string, snap *Snapshot) error {
+	return errors.New("disk full")
+}
+
+func TestPersistRollback(t *testing.T) {
+	d := &door{}
+	d.entry = newTestTable(t, doorDesc()).NewEntry(d)
+	d.entry.SetStore("door-1", failStore{NewMemStore()})
+
+	state, eot, err := d.entry.Transit("Open")
+	var perr *PersistError
+	if !errors.As(err, &perr) || perr.State != "Closed" {
+		t.Fatalf("error %v, expected *PersistError", err)
+	}
+	if state.Name != "Closed" || !eot {
+		t.Fatalf("state %s eot %v, expected Closed rolled back", state.Name, eot)
+	}
+	expectState(t, d.entry, "Closed")
+}
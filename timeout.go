@@ -0,0 +1,107 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FSM State Timeout
+type StateTimeout struct {
+	After time.Duration // duration the Entry may stay in the State
+	Event Event         // Event injected when the duration elapsed
+}
+
+// errStaleTimeout represents the timeout fired after the Entry left the State
+var errStaleTimeout = errors.New("stale timeout")
+
+// armTimer stops the timer of the previous State, starts the timer of the state
+// e.mu MUST be locked
+func (e *Entry[OWNER, USERDATA]) armTimer(state State) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.timerGen++
+
+	to, ok := e.table.Timeouts[state]
+	if !ok {
+		return
+	}
+	gen := e.timerGen
+	e.timer = e.table.Clock.AfterFunc(to.After, func() {
+		e.fireTimeout(gen, to.Event.Name)
+	})
+}
+
+// fireTimeout injects the timeout event,
+// posted to the queue if Run() is processing the Entry, transits directly otherwise
+// the Result of the direct transition is passed to the callback of OnResult() as Run() does,
+// and logged if no handle accepted the event
+func (e *Entry[OWNER, USERDATA]) fireTimeout(gen uint64, ev string) {
+	var d USERDATA
+
+	e.queue.mu.Lock()
+	running := e.queue.running
+	e.queue.mu.Unlock()
+
+	if running {
+		e.queue.push(posted[USERDATA]{event: ev, data: d, gen: gen})
+		return
+	}
+	state, eot, err := e.transit(context.Background(), ev, d, gen)
+	if err == errStaleTimeout {
+		return
+	}
+	if from, ok := unhandled(err); ok {
+		// the transition logs the handled events only
+		e.mu.Lock()
+		e.appendLog(&TrnasitLog{
+			time:  e.table.Clock.Now(),
+			state: from,
+			event: ev,
+			next:  from,
+			err:   err,
+		})
+		e.mu.Unlock()
+	}
+
+	e.queue.mu.Lock()
+	onRes := e.queue.onRes
+	e.queue.mu.Unlock()
+	if onRes != nil {
+		onRes(Result{Event: ev, State: state, EOT: eot, Err: err})
+	}
+}
+
+// unhandled returns the State of the event no handle accepted
+func unhandled(err error) (string, bool) {
+	var rejected *GuardRejected
+	var undefined *UndefinedHandle
+	switch {
+	case errors.As(err, &rejected):
+		return rejected.State, true
+	case errors.As(err, &undefined):
+		return undefined.State, true
+	}
+	return "", false
+}
+
+// staleTimer reports the timer of gen is stopped or replaced
+func (e *Entry[OWNER, USERDATA]) staleTimer(gen uint64) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return gen != e.timerGen
+}
+
+// StopTimer cancels the state timeout of the current State
+// the timeout of following States still works
+func (e *Entry[OWNER, USERDATA]) StopTimer() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.timerGen++
+}
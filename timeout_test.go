@@ -0,0 +1,100 @@
+package fsm
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// timeoutTable expires A to B, T moves B back to A
+func timeoutTable(t *testing.T, clock Clock, guard GuardFunc[*door, *int]) *Table[*door, *int] {
+	return newTestTable(t, &TableDesc[*door, *int]{
+		InitState: "A",
+		LogMax:    10,
+		Clock:     clock,
+		States: []StateDesc[*door, *int]{
+			{State: "A", Timeout: time.Second, TimeoutEvent: "Expire", Events: []EventDesc[*door, *int]{
+				{Event: "Expire", Guard: guard, Func: retCode, CandList: []string{"B"}},
+				{Event: "T", Func: retCode, CandList: []string{"A"}},
+			}},
+			{State: "B", Events: []EventDesc[*door, *int]{
+				{Event: "Expire", Func: retCode, CandList: []string{"B"}},
+				{Event: "T", Func: retCode, CandList: []string{"A"}},
+			}},
+		},
+	})
+}
+
+func TestTimeoutFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	d := &door{}
+	d.entry = timeoutTable(t, clock, nil).NewEntry(d)
+
+	var results []Result
+	d.entry.OnResult(func(r Result) { results = append(results, r) })
+
+	clock.Advance(500 * time.Millisecond)
+	expectState(t, d.entry, "A")
+	clock.Advance(500 * time.Millisecond)
+	expectState(t, d.entry, "B")
+	if len(results) != 1 || results[0].Event != "Expire" || results[0].State.Name != "B" {
+		t.Fatalf("results %+v, expected Expire to B", results)
+	}
+
+	// B has no timeout, A arms it again
+	if clock.Timers() != 0 {
+		t.Fatalf("%d timers, expected 0", clock.Timers())
+	}
+	d.entry.Transit("T")
+	if clock.Timers() != 1 {
+		t.Fatalf("%d timers, expected 1", clock.Timers())
+	}
+	d.entry.StopTimer()
+	clock.Advance(time.Hour)
+	expectState(t, d.entry, "A")
+}
+
+func TestTimeoutRejectedReported(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	reject := func(d *door, ev Event, n *int) bool { return false }
+	d := &door{}
+	d.entry = timeoutTable(t, clock, reject).NewEntry(d)
+
+	var results []Result
+	d.entry.OnResult(func(r Result) { results = append(results, r) })
+	clock.Advance(time.Second)
+
+	var rejected *GuardRejected
+	if len(results) != 1 || !errors.As(results[0].Err, &rejected) {
+		t.Fatalf("results %+v, expected *GuardRejected", results)
+	}
+	logs := d.entry.LogRecords(0)
+	if len(logs) != 1 || logs[0].Event != "Expire" || logs[0].Err != results[0].Err.Error() {
+		t.Fatalf("logs %+v, expected the rejected timeout", logs)
+	}
+}
+
+func TestTimeoutSystemClockSerialized(t *testing.T) {
+	// the handles of the plain Entry never run at the same time
+	var running atomic.Int32
+	exclusive := func(d *door, ev Event, n *int) bool {
+		if running.Add(1) > 1 {
+			t.Errorf("%s handled while other transition runs", ev.Name)
+		}
+		time.Sleep(10 * time.Microsecond)
+		running.Add(-1)
+		return true
+	}
+	tbl := timeoutTable(t, nil, exclusive)
+	tbl.Timeouts[State{"A"}].After = time.Microsecond
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	// one goroutine transits the Entry, racing with the timeouts only
+	for j := 0; j < 1000; j++ {
+		d.entry.Transit("Expire")
+		d.entry.Transit("T")
+	}
+	d.entry.StopTimer()
+}
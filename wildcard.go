@@ -0,0 +1,108 @@
+package fsm
+
+import (
+	fsmerror "github.com/HaesungSeo/goFSM/v2/internal/fsmerrors"
+)
+
+// Wildcards of StateDesc.State and EventDesc.Event
+const (
+	AnyState = "*" // the State handling the event in any State
+	AnyEvent = "*" // the event handling any event unhandled by the State
+)
+
+// Ambiguous Wildcard Error
+type AmbiguousWildcard struct {
+	State  string
+	Event  string
+	Reason string
+	Err    error
+}
+
+func (e *AmbiguousWildcard) Error() string {
+	return e.Err.Error() + ": State=" + e.State + ", Event=" + e.Event + ", Reason=" + e.Reason
+}
+
+func (e *AmbiguousWildcard) Unwrap() error { return e.Err }
+
+// checkWildcards checks the wildcards are used only as the handled State and Event
+func checkWildcards[OWNER any, USERDATA any](d *TableDesc[OWNER, USERDATA]) error {
+	wildcard := func(state string, event string, reason string) error {
+		return &AmbiguousWildcard{State: state, Event: event, Reason: reason, Err: fsmerror.ErrWildcard}
+	}
+
+	if d.InitState == AnyState {
+		return wildcard(d.InitState, "", "wildcard initial state")
+	}
+	for _, state := range d.FinalStates {
+		if state == AnyState {
+			return wildcard(state, "", "wildcard final state")
+		}
+	}
+	for _, g := range d.Groups {
+		for _, state := range g.States {
+			if state == AnyState {
+				return wildcard(state, "", "wildcard state in group "+g.Name)
+			}
+		}
+	}
+
+	for _, state := range d.States {
+		if state.Parent == AnyState || state.Initial == AnyState {
+			return wildcard(state.State, "", "wildcard parent or initial")
+		}
+		if state.Timeout > 0 && state.TimeoutEvent == AnyEvent {
+			return wildcard(state.State, state.TimeoutEvent, "wildcard timeout event")
+		}
+		for _, ev := range state.Defer {
+			if ev == AnyEvent {
+				return wildcard(state.State, ev, "wildcard deferred event")
+			}
+		}
+		if state.State == AnyState {
+			switch {
+			case state.Parent != "" || state.Initial != "" || state.Parallel:
+				return wildcard(state.State, "", "wildcard state in hierarchy")
+			case state.OnEnter != nil || state.OnExit != nil || state.OnEnterCtx != nil || state.OnExitCtx != nil:
+				return wildcard(state.State, "", "wildcard state with entry/exit action")
+			case state.Timeout > 0:
+				return wildcard(state.State, "", "wildcard state with timeout")
+			case len(state.Defer) > 0:
+				return wildcard(state.State, "", "wildcard state with deferred events")
+			}
+		}
+		for _, event := range state.Events {
+			for _, target := range event.nexts() {
+				if target == AnyState {
+					return wildcard(state.State, event.Event, "wildcard next state")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// key of Table.Handles
+type handleKey struct {
+	state State
+	event Event
+}
+
+// candidates returns the keys of the handles for the {State, Event}, in order of precedence
+//
+//	the event of the State, then of its ancestors from the innermost
+//	AnyEvent of the State, then of its ancestors from the innermost
+//	the event of AnyState
+//	AnyEvent of AnyState
+func (tbl *Table[OWNER, USERDATA]) candidates(state State, event Event) []handleKey {
+	path := tbl.path(state)
+	keys := make([]handleKey, 0, 2*len(path)+2)
+	for i := len(path) - 1; i >= 0; i-- {
+		keys = append(keys, handleKey{path[i], event})
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		keys = append(keys, handleKey{path[i], Event{AnyEvent}})
+	}
+	keys = append(keys, handleKey{State{AnyState}, event})
+	keys = append(keys, handleKey{State{AnyState}, Event{AnyEvent}})
+	return keys
+}
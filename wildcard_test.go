@@ -0,0 +1,76 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWildcards(t *testing.T) {
+	var handled []string
+	handle := func(name string) HandleFuncv2[*door, *int] {
+		return func(d *door, ev Event, n *int) (HandleRetCode, error) {
+			handled = append(handled, name)
+			return ExitOK, nil
+		}
+	}
+	type E = EventDesc[*door, *int]
+	tbl := newTestTable(t, &TableDesc[*door, *int]{
+		InitState:   "A",
+		FinalStates: []string{"Aborted"},
+		Strict:      true,
+		States: []StateDesc[*door, *int]{
+			{State: AnyState, Events: []E{
+				{Event: "Abort", Func: handle("any state"), CandList: []string{"Aborted"}},
+				{Event: AnyEvent, Func: handle("any state, any event"), CandList: []string{"A"}},
+			}},
+			{State: "A", Events: []E{
+				{Event: "Go", Func: handle("A"), CandList: []string{"B"}},
+				{Event: "Reset", Func: handle("A"), CandList: []string{"A"}},
+				{Event: AnyEvent, Func: handle("A, any event"), CandList: []string{"A"}},
+			}},
+			{State: "B", Events: []E{
+				{Event: "Go", Func: handle("B"), CandList: []string{"A"}},
+				{Event: "Stop", Func: handle("B"), CandList: []string{"B"}},
+			}},
+		},
+	})
+	d := &door{}
+	d.entry = tbl.NewEntry(d)
+
+	// in order of precedence, AnyEvent of A hides Abort of AnyState
+	for _, ev := range []string{"Stop", "Go", "Reset", "Abort", "Go", "Abort"} {
+		if _, _, err := d.entry.Transit(ev); err != nil {
+			t.Fatal(ev, err)
+		}
+	}
+	expected := []string{"A, any event", "A", "any state, any event", "A, any event", "A", "any state"}
+	if len(handled) != len(expected) {
+		t.Fatalf("handled %v, expected %v", handled, expected)
+	}
+	for i := range expected {
+		if handled[i] != expected[i] {
+			t.Fatalf("handled %v, expected %v", handled, expected)
+		}
+	}
+	expectState(t, d.entry, "Aborted")
+}
+
+func TestAmbiguousWildcard(t *testing.T) {
+	nop := func(d *door, s State, ev Event, n *int) error { return nil }
+	type E = EventDesc[*door, *int]
+	for name, states := range map[string][]StateDesc[*door, *int]{
+		"next state": {
+			{State: "A", Events: []E{{Event: "Go", Func: retCode, CandList: []string{AnyState}}}},
+		},
+		"entry action": {
+			{State: "A", Events: []E{{Event: "Go", Func: retCode, CandList: []string{"A"}}}},
+			{State: AnyState, OnEnter: nop, Events: []E{{Event: "Go", Func: retCode, CandList: []string{"A"}}}},
+		},
+	} {
+		_, err := NewTable(&TableDesc[*door, *int]{InitState: "A", States: states})
+		var w *AmbiguousWildcard
+		if !errors.As(err, &w) {
+			t.Fatalf("%s: error %v, expected *AmbiguousWildcard", name, err)
+		}
+	}
+}